@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// checkpointPath is the file that holds the progress of backfill jobs, so an interrupted or
+// failed backfill resumes where it stopped. It maps a backfill job to the last day until which
+// all the days were completed successfully.
+var checkpointPath = filepath.Join(dataDir, "backfill-checkpoint.json")
+
+// backfill fetches historical data day by day. Usage:
+//
+//	fetch [flags] backfill -from YYYY-MM-DD [-to YYYY-MM-DD] [-source noaa,uwyo] [-only megido] [-force]
+func backfill(ctx context.Context, args []string, workers map[string]int) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	var (
+		srcNames = fs.String("source", "", "Which sources to backfill (comma separated, all if empty)")
+		from     = fs.String("from", "", "First day to backfill, in YYYY-MM-DD format")
+		to       = fs.String("to", "", "Last day to backfill, in YYYY-MM-DD format (default yesterday)")
+		only     = fs.String("only", "", "Names of the locations to backfill (comma separated, all if empty)")
+		force    = fs.Bool("force", false, "Fetch also days that already have data")
+	)
+	fs.Parse(args)
+
+	srcs, err := backfillSources(*srcNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+	locs, err := selectLocations(*only)
+	if err != nil {
+		log.Fatal(err)
+	}
+	first, err := time.ParseInLocation(dateFormat, *from, timezone)
+	if err != nil {
+		log.Fatalf("Invalid -from: %s", err)
+	}
+	last := time.Now().In(timezone).AddDate(0, 0, -1)
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, timezone)
+	if *to != "" {
+		last, err = time.ParseInLocation(dateFormat, *to, timezone)
+		if err != nil {
+			log.Fatalf("Invalid -to: %s", err)
+		}
+	}
+	if last.Before(first) {
+		log.Fatalf("Invalid range: %s is before %s", last.Format(dateFormat), first.Format(dateFormat))
+	}
+
+	err = checkFiles([]string{indexPath, checkpointPath}, *recoverMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loadIndex()
+
+	job := backfillJob(srcs, locs, *from, *to)
+	complete, failed := backfillRange(ctx, job, srcs, locs, first, last, *force, workers)
+	if !complete {
+		log.Printf("Backfill %s is incomplete, resume it to retry the incomplete days", job)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// backfillRange fetches the days from first to last, resuming the given job after its
+// checkpoint. It returns whether all the days were completed, and whether any day failed
+// according to the -fail policy. A day is completed if every source either has data for all the
+// locations or was fetched without errors. The checkpoint is advanced only over completed days,
+// and it is removed when the whole range is completed.
+func backfillRange(ctx context.Context, job string, srcs []Source, locs []Location, first, last time.Time, force bool, workers map[string]int) (complete, failed bool) {
+	checkpoints := map[string]string{}
+	mustDecodeJson(checkpointPath, &checkpoints)
+	day := first
+	if done, ok := checkpoints[job]; ok {
+		t, err := time.ParseInLocation(dateFormat, done, timezone)
+		if err != nil {
+			log.Fatalf("Invalid checkpoint of %s: %s", job, err)
+		}
+		day = t.AddDate(0, 0, 1)
+		log.Printf("Resuming backfill %s after %s", job, done)
+	}
+
+	// complete is cleared after the first incomplete day. From then on, the checkpoint is not
+	// advanced, so that a resumed backfill retries the incomplete day.
+	complete = true
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		path := outputPath(day)
+		err := checkFiles([]string{path}, *recoverMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		st := newStore()
+		daySrcs := srcs
+		if !force {
+			daySrcs = missingSources(st, path, srcs, locs)
+		}
+		if len(daySrcs) == 0 {
+			log.Printf("Skipping %s, all sources already present", day.Format(dateFormat))
+		} else {
+			statuses := run(ctx, st, daySrcs, locs, day, day.AddDate(0, 0, 1), true, workers)
+			if ctx.Err() != nil {
+				log.Fatalf("Backfill interrupted at %s", day.Format(dateFormat))
+			}
+			derive(st)
+			st.flush()
+			writeIndex()
+			if shouldFail(*failPolicy, statuses) {
+				log.Printf("Backfill of %s failed, source statuses: %v", day.Format(dateFormat), statuses)
+				failed = true
+			}
+			if incomplete := incompleteSources(st, path, daySrcs, locs, statuses); len(incomplete) > 0 {
+				log.Printf("Backfill of %s is incomplete, missing sources: %v", day.Format(dateFormat), sourceNames(incomplete))
+				complete = false
+			}
+		}
+
+		if complete {
+			checkpoints[job] = day.Format(dateFormat)
+			mustEncodeJson(checkpointPath, checkpoints)
+		}
+	}
+
+	if complete {
+		delete(checkpoints, job)
+		mustEncodeJson(checkpointPath, checkpoints)
+		log.Printf("Backfill %s completed", job)
+	}
+	return complete, failed
+}
+
+// backfillSources returns the sources listed in a comma separated list of names, which must be
+// sources that can be backfilled. An empty list selects all the sources that can be backfilled.
+func backfillSources(names string) ([]Source, error) {
+	srcs, err := selectSources(names)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Source
+	for _, src := range srcs {
+		if _, ok := src.(backfiller); ok {
+			selected = append(selected, src)
+		} else if strings.TrimSpace(names) != "" {
+			return nil, fmt.Errorf("source %q can not be backfilled, it fetches only current data", src.Name())
+		}
+	}
+	return selected, nil
+}
+
+// backfillJob returns the key of a backfill job in the checkpoints file. The key is built from
+// the flags as given, and not from the resolved dates, so that a backfill without -to that is
+// resumed on a later day is the same job.
+func backfillJob(srcs []Source, locs []Location, from, to string) string {
+	var locNames []string
+	for _, l := range locs {
+		locNames = append(locNames, l.Name)
+	}
+	return fmt.Sprintf("%s:%s:%s..%s", strings.Join(sourceNames(srcs), ","), strings.Join(locNames, ","), from, to)
+}
+
+// missingSources returns the sources that have no data in the given day file for at least one
+// of the locations.
+func missingSources(st *store, path string, srcs []Source, locs []Location) []Source {
+	content := st.day(path)
+	var missing []Source
+	for _, src := range srcs {
+		for _, loc := range locs {
+			if !hasData(content, storageKey(src, loc), src.Name()) {
+				missing = append(missing, src)
+				break
+			}
+		}
+	}
+	return missing
+}
+
+// incompleteSources returns the sources that are missing data in the given day file after a
+// fetch, and that did not complete the fetch successfully. A source that was fetched without
+// errors is not missing anything, even if it had no data for that day.
+func incompleteSources(st *store, path string, srcs []Source, locs []Location, statuses map[string]string) []Source {
+	var incomplete []Source
+	for _, src := range missingSources(st, path, srcs, locs) {
+		if statuses[src.Name()] != statusOK {
+			incomplete = append(incomplete, src)
+		}
+	}
+	return incomplete
+}
+
+// hasData returns true if the day has data of the given source in any of the hours.
+func hasData(content dayData, l location, name string) bool {
+	for _, locs := range content {
+		raw, ok := locs[l][name]
+		if ok && string(raw) != "null" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillSources(t *testing.T) {
+	t.Parallel()
+
+	srcs, err := backfillSources("")
+	require.NoError(t, err)
+	var names []string
+	for _, src := range srcs {
+		names = append(names, src.Name())
+	}
+	assert.NotContains(t, names, "ims")
+	assert.Contains(t, names, "noaa")
+
+	srcs, err = backfillSources("noaa, uwyo")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(srcs))
+
+	_, err = backfillSources("noaa,ims")
+	assert.Error(t, err)
+
+	_, err = backfillSources("unknown")
+	assert.Error(t, err)
+}
+
+func TestBackfillJob(t *testing.T) {
+	t.Parallel()
+
+	srcs := []Source{lookupSource("noaa"), lookupSource("uwyo")}
+	locs := []Location{{Name: "megido"}, {Name: "zefat"}}
+	assert.Equal(t, "noaa,uwyo:megido,zefat:2024-10-01..", backfillJob(srcs, locs, "2024-10-01", ""))
+	assert.Equal(t, "noaa,uwyo:megido,zefat:2024-10-01..2024-10-31", backfillJob(srcs, locs, "2024-10-01", "2024-10-31"))
+}
+
+// flakySource fails the fetches of the days in fail, and records the fetched days.
+type flakySource struct {
+	fakeSource
+	fail map[string]bool
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+func (s *flakySource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	day := start.Format(dateFormat)
+	s.mu.Lock()
+	s.fetched = append(s.fetched, day)
+	s.mu.Unlock()
+	if s.fail[day] {
+		return nil, errors.New("unavailable")
+	}
+	return s.fakeSource.Fetch(ctx, loc, start, end)
+}
+
+func TestBackfillResume(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	a := &flakySource{fakeSource: fakeSource{name: "a"}}
+	b := &flakySource{fakeSource: fakeSource{name: "b"}, fail: map[string]bool{"2020-06-21": true}}
+	srcs := []Source{a, b}
+	locs := []Location{{Name: "megido"}}
+	first := time.Date(2020, time.June, 20, 0, 0, 0, 0, timezone)
+	last := first.AddDate(0, 0, 2)
+	job := backfillJob(srcs, locs, "2020-06-20", "2020-06-22")
+
+	// Source b fails on the second day. With the default fail policy the backfill does not fail,
+	// but the checkpoint stays at the first day.
+	complete, failed := backfillRange(context.Background(), job, srcs, locs, first, last, false, nil)
+	assert.False(t, complete)
+	assert.False(t, failed)
+	assert.Equal(t, []string{"2020-06-20", "2020-06-21", "2020-06-22"}, a.fetched)
+	assert.Equal(t, []string{"2020-06-20", "2020-06-21", "2020-06-22"}, b.fetched)
+	checkpoints := map[string]string{}
+	mustDecodeJson(checkpointPath, &checkpoints)
+	assert.Equal(t, map[string]string{job: "2020-06-20"}, checkpoints)
+
+	// The resumed backfill fetches only the missing source of the second day.
+	a.fetched, b.fetched, b.fail = nil, nil, nil
+	complete, failed = backfillRange(context.Background(), job, srcs, locs, first, last, false, nil)
+	assert.True(t, complete)
+	assert.False(t, failed)
+	assert.Empty(t, a.fetched)
+	assert.Equal(t, []string{"2020-06-21"}, b.fetched)
+	checkpoints = map[string]string{}
+	mustDecodeJson(checkpointPath, &checkpoints)
+	assert.Empty(t, checkpoints)
+
+	st := newStore()
+	assert.Empty(t, missingSources(st, outputPath(first.AddDate(0, 0, 1)), srcs, locs))
+}
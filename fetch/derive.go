@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/airsounds/data/fetch/ims"
+	"github.com/airsounds/data/fetch/noaa"
+	"github.com/airsounds/data/fetch/runway"
+	"github.com/airsounds/data/fetch/soaring"
+	"github.com/airsounds/data/fetch/uwyo"
+)
+
+const (
+	// derivedKey is the key of the soaring products in the day files. The products are stored
+	// per profile source name.
+	derivedKey = "derived"
+	// runwayKey is the key of the runway wind reports in the day files. The reports are stored
+	// per wind source name.
+	runwayKey = "runway"
+
+	// msToKnots converts m/s, the unit of the IMS wind speed, to knots.
+	msToKnots = 3600 / 1852.0
+)
+
+// derive computes the soaring products and the runway wind reports of all the locations in the
+// modified day files of the store, from the stored profiles and the IMS surface forecast.
+func derive(st *store) {
+	for _, path := range st.paths() {
+		for _, locs := range st.day(path) {
+			for _, loc := range locations {
+				srcs := locs[location(loc.Name)]
+				if srcs == nil {
+					continue
+				}
+				station := locs[storageKey(lookupSource("uwyo"), loc)]
+				deriveSoaring(loc, srcs, station)
+				deriveRunway(loc, srcs, station)
+			}
+		}
+		log.Printf("Derived data of %s", path)
+	}
+}
+
+// deriveSoaring computes the soaring products of a location from the NOAA profile of the location
+// and the UWYO profile of its station.
+func deriveSoaring(loc Location, srcs, station sources) {
+	var f ims.HourlyForecast
+	if !srcs.decode("ims", &f) {
+		return
+	}
+	var dew *float64
+	if f.RelHum > 0 {
+		d := soaring.DewPoint(float64(f.Temp), float64(f.RelHum))
+		dew = &d
+	}
+	compute := func(p soaring.Profile) soaring.Derived {
+		return soaring.Compute(p, float64(loc.Alt), float64(f.Temp), dew)
+	}
+
+	derived := map[string]soaring.Derived{}
+	var n noaa.NOAA
+	if srcs.decode("noaa", &n) {
+		derived["noaa"] = compute(soaring.FromNOAA(&n))
+	}
+	var u uwyo.UWYO
+	if station.decode("uwyo", &u) {
+		derived["uwyo"] = compute(soaring.FromUWYO(&u))
+	}
+	if len(derived) > 0 {
+		srcs.set(derivedKey, derived)
+	}
+}
+
+// deriveRunway computes the runway wind reports of a location from the IMS surface forecast and
+// the lowest level of the NOAA and UWYO profiles.
+func deriveRunway(loc Location, srcs, station sources) {
+	if loc.RunwayDir == 0 {
+		return
+	}
+	maxCrosswind := float64(loc.MaxCrosswind)
+	if maxCrosswind == 0 {
+		maxCrosswind = defaultMaxCrosswind
+	}
+	compute := func(windDir, windSpeed float64) runway.Report {
+		return runway.Compute(loc.RunwayDir, windDir, windSpeed, maxCrosswind)
+	}
+
+	reports := map[string]runway.Report{}
+	var f ims.HourlyForecast
+	if srcs.decode("ims", &f) {
+		reports["ims"] = compute(float64(f.WindDir), float64(f.WindSpeed)*msToKnots)
+	}
+	var n noaa.NOAA
+	if srcs.decode("noaa", &n) {
+		// The lowest level that has wind.
+		for i := 0; i < len(n.WindDir) && i < len(n.WindSpeed); i++ {
+			if n.WindDir[i] != nil && n.WindSpeed[i] != nil {
+				reports["noaa"] = compute(float64(*n.WindDir[i]), float64(*n.WindSpeed[i]))
+				break
+			}
+		}
+	}
+	var u uwyo.UWYO
+	if station.decode("uwyo", &u) {
+		// The lowest level that has wind.
+		for i := 0; i < len(u.WindDir) && i < len(u.WindSpeed); i++ {
+			if u.WindDir[i] != nil && u.WindSpeed[i] != nil {
+				reports["uwyo"] = compute(float64(*u.WindDir[i]), float64(*u.WindSpeed[i]))
+				break
+			}
+		}
+	}
+	if len(reports) > 0 {
+		srcs.set(runwayKey, reports)
+	}
+}
+
+// parseMaxCrosswind parses the crosswind limit in knots of the -max-crosswind flag.
+func parseMaxCrosswind(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+		return 0, fmt.Errorf("invalid -max-crosswind %q, expected a non negative number of knots", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaxCrosswind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "10", want: 10},
+		{value: "7.5", want: 7.5},
+		{value: " 0 ", want: 0},
+		{value: "", wantErr: true},
+		{value: "ten", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMaxCrosswind(tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, "value %q", tt.value)
+			continue
+		}
+		if assert.NoError(t, err, "value %q", tt.value) {
+			assert.Equal(t, tt.want, got, "value %q", tt.value)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Modes of recovering corrupt data files.
+const (
+	recoverAbort      = "abort"
+	recoverGit        = "git"
+	recoverQuarantine = "quarantine"
+)
+
+// quarantineDir is the directory to which corrupt files are moved.
+var quarantineDir = filepath.Join(dataDir, "quarantine")
+
+func validRecoverMode(mode string) bool {
+	switch mode {
+	case recoverAbort, recoverGit, recoverQuarantine:
+		return true
+	}
+	return false
+}
+
+// writeFileAtomic writes a file such that it either has its previous content or the complete
+// new content, even if the process crashes. The content is written to a temporary file in the
+// same directory, synced to the disk and then renamed over the original file.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	// Cleanup in case of failure, after rename the temporary file does not exist.
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = write(f)
+	if err != nil {
+		return fmt.Errorf("writing %s: %s", path, err)
+	}
+	err = f.Chmod(0644)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir syncs a directory, to persist a rename within it.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// dayPaths returns the paths of the day files between start and end.
+func dayPaths(start, end time.Time) []string {
+	var paths []string
+	for t := start; t.Before(end); t = t.Add(24 * time.Hour) {
+		paths = append(paths, outputPath(t))
+	}
+	if last := outputPath(end); len(paths) == 0 || paths[len(paths)-1] != last {
+		paths = append(paths, last)
+	}
+	return paths
+}
+
+// checkFiles verifies that the given JSON files are not corrupt, and recovers the corrupt ones.
+// Files that do not exist are ignored.
+func checkFiles(paths []string, mode string) error {
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if json.Valid(content) {
+			continue
+		}
+		log.Printf("Found corrupt file %s", path)
+		err = recoverFile(path, mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverFile recovers a corrupt file according to the recovery mode.
+func recoverFile(path string, mode string) error {
+	switch mode {
+	case recoverGit:
+		err := restoreFromGit(path)
+		if err == nil {
+			log.Printf("Restored %s from git", path)
+			return nil
+		}
+		log.Printf("Failed restoring %s from git: %s", path, err)
+		return quarantine(path)
+	case recoverQuarantine:
+		return quarantine(path)
+	default:
+		return fmt.Errorf("corrupt file %s, recover mode is %q", path, mode)
+	}
+}
+
+// restoreFromGit restores a file to its content in git HEAD.
+func restoreFromGit(path string) error {
+	content, err := exec.Command("git", "show", "HEAD:./"+filepath.ToSlash(path)).Output()
+	if err != nil {
+		return fmt.Errorf("git show: %s", err)
+	}
+	if !json.Valid(content) {
+		return fmt.Errorf("corrupt in git HEAD")
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// quarantine moves a corrupt file to the quarantine directory, so it is not used and can be
+// inspected later.
+func quarantine(path string) error {
+	dst := filepath.Join(quarantineDir, fmt.Sprintf("%s.%d", path, time.Now().Unix()))
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(path, dst)
+	if err != nil {
+		return fmt.Errorf("quarantining %s: %s", path, err)
+	}
+	log.Printf("Quarantined %s to %s", path, dst)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2020", "06", "20.json")
+
+	err := writeFileAtomic(path, writeString(`{"a": 1}`))
+	require.NoError(t, err)
+	assertFile(t, path, `{"a": 1}`)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// A failed write leaves the original file intact and no temporary files.
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"a": `)
+		require.NoError(t, err)
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	assertFile(t, path, `{"a": 1}`)
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestCheckFiles(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.MkdirAll("2020/06", 0755))
+	require.NoError(t, ioutil.WriteFile("2020/06/20.json", []byte(`{"valid": true}`), 0644))
+	paths := []string{"2020/06/20.json", "2020/06/21.json", "2020/06/22.json"}
+
+	// Valid and missing files.
+	require.NoError(t, checkFiles(paths, recoverAbort))
+
+	// The abort mode returns an error and leaves the corrupt file.
+	require.NoError(t, ioutil.WriteFile("2020/06/21.json", []byte(`{"valid": tr`), 0644))
+	assert.Error(t, checkFiles(paths, recoverAbort))
+	assertFile(t, "2020/06/21.json", `{"valid": tr`)
+
+	// The quarantine mode moves the corrupt file to the quarantine directory.
+	require.NoError(t, checkFiles(paths, recoverQuarantine))
+	_, err := os.Stat("2020/06/21.json")
+	assert.True(t, os.IsNotExist(err))
+	quarantined, err := filepath.Glob(filepath.Join(quarantineDir, "2020/06/21.json.*"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(quarantined))
+	assertFile(t, quarantined[0], `{"valid": tr`)
+	assertFile(t, "2020/06/20.json", `{"valid": true}`)
+
+	// The git mode quarantines a file that can not be restored from git.
+	require.NoError(t, ioutil.WriteFile("2020/06/22.json", []byte(`]`), 0644))
+	require.NoError(t, checkFiles(paths, recoverGit))
+	_, err = os.Stat("2020/06/22.json")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCheckFilesRestoreFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	chdir(t, t.TempDir())
+	path := "index.json"
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"valid": true}`), 0644))
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	git("init", "-q")
+	git("add", path)
+	git("commit", "-q", "-m", "data")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"valid": tr`), 0644))
+	require.NoError(t, checkFiles([]string{path}, recoverGit))
+	assertFile(t, path, `{"valid": true}`)
+}
+
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, string(got))
+	}
+}
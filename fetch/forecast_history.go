@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/airsounds/data/fetch/history"
+)
+
+// historyKey is the key in the day files of the forecast history of the forecast sources of a
+// location, which holds the issuances of each source.
+const historyKey = "history"
+
+// historyInterval is the interval of the -history-interval flag.
+var historyInterval time.Duration
+
+// historyEnabled returns true if the forecast history is kept, which is when any of the
+// retention policy flags is set.
+func historyEnabled() bool {
+	return *historyKeep > 0 || historyInterval > 0
+}
+
+// addHistory adds an issuance of the given source to the forecast history, according to the
+// retention policy of the flags.
+func (s sources) addHistory(name string, iss history.Issuance) {
+	hists := map[string][]history.Issuance{}
+	if raw, ok := s[historyKey]; ok {
+		err := json.Unmarshal(raw, &hists)
+		if err != nil {
+			log.Printf("Decode forecast history: %v", err)
+			hists = map[string][]history.Issuance{}
+		}
+	}
+	policy := history.Policy{Keep: *historyKeep, Interval: historyInterval}
+	hists[name] = history.Add(hists[name], iss, policy)
+	s.set(historyKey, hists)
+}
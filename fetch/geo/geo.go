@@ -0,0 +1,45 @@
+// Package geo selects the nearest of a set of points on the earth.
+package geo
+
+import "math"
+
+// earthRadius in km.
+const earthRadius = 6371.0
+
+// elevationWeight is the distance in km that is as significant as a meter of elevation
+// difference when selecting the nearest point, such that a point 100 m higher or lower is as
+// near as a point 10 km away at the same elevation.
+const elevationWeight = 0.1
+
+// Point is a location on the earth.
+type Point struct {
+	// Lat and Long in degrees.
+	Lat, Long float64
+	// Alt in meters.
+	Alt float64
+}
+
+// Distance returns the great-circle distance between two points in km.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLong := lat2-lat1, radians(b.Long-a.Long)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLong/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Nearest returns the index of the candidate that is nearest to p, by the great-circle distance
+// and the elevation difference. It returns -1 if there are no candidates.
+func Nearest(p Point, candidates []Point) int {
+	nearest, min := -1, math.Inf(1)
+	for i, c := range candidates {
+		cost := Distance(p, c) + elevationWeight*math.Abs(c.Alt-p.Alt)
+		if cost < min {
+			nearest, min = i, cost
+		}
+	}
+	return nearest
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
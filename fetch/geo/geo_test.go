@@ -0,0 +1,35 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	// Bet Dagan to Megido.
+	betDagan := Point{Lat: 32.00, Long: 34.81}
+	megido := Point{Lat: 32.597, Long: 35.23}
+	assert.InDelta(t, 77.1, Distance(betDagan, megido), 0.5)
+
+	assert.Equal(t, 0.0, Distance(megido, megido))
+
+	// A degree of latitude.
+	assert.InDelta(t, 111.2, Distance(Point{Lat: 0, Long: 0}, Point{Lat: 1, Long: 0}), 0.1)
+}
+
+func TestNearest(t *testing.T) {
+	t.Parallel()
+
+	p := Point{Lat: 32.6, Long: 35.2, Alt: 60}
+	candidates := []Point{
+		{Lat: 32.0, Long: 34.8, Alt: 35},   // ~77 km.
+		{Lat: 32.7, Long: 35.2, Alt: 60},   // ~11 km.
+		{Lat: 32.65, Long: 35.2, Alt: 900}, // ~6 km, but 840 m higher.
+	}
+	assert.Equal(t, 1, Nearest(p, candidates))
+	assert.Equal(t, 0, Nearest(p, candidates[:1]))
+	assert.Equal(t, -1, Nearest(p, nil))
+}
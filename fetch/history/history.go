@@ -0,0 +1,65 @@
+// Package history keeps the issuances of a forecast for a target time, according to a retention
+// policy.
+package history
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Issuance is a single issuance of a forecast for a target time.
+type Issuance struct {
+	// Issued is the initialization time of the model run of the forecast, or its fetch time if
+	// the run is unknown.
+	Issued time.Time
+	// Hash of the forecast data.
+	Hash string
+	Data json.RawMessage
+}
+
+// Policy is the retention policy of the issuances.
+type Policy struct {
+	// Keep is the number of latest issuances to keep. Zero keeps all of them.
+	Keep int
+	// Interval, if not zero, keeps only the latest issuance of each interval.
+	Interval time.Duration
+}
+
+// Add adds an issuance to a history that is sorted by issue time and applies the retention
+// policy. An issuance with the same issue time as an existing one replaces it, and an issuance
+// with the same data as the latest issuance is not added. The returned history is sorted by
+// issue time.
+func Add(hist []Issuance, iss Issuance, p Policy) []Issuance {
+	var out []Issuance
+	for _, h := range hist {
+		if !h.Issued.Equal(iss.Issued) {
+			out = append(out, h)
+		}
+	}
+	if n := len(out); n > 0 && out[n-1].Hash == iss.Hash && out[n-1].Issued.Before(iss.Issued) {
+		return p.apply(out)
+	}
+	out = append(out, iss)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Issued.Before(out[j].Issued) })
+	return p.apply(out)
+}
+
+// apply the policy on a history that is sorted by issue time.
+func (p Policy) apply(hist []Issuance) []Issuance {
+	if p.Interval > 0 {
+		var thinned []Issuance
+		for i, h := range hist {
+			// Keep the issuance if it is the last one in its interval.
+			last := i == len(hist)-1 || !hist[i+1].Issued.Truncate(p.Interval).Equal(h.Issued.Truncate(p.Interval))
+			if last {
+				thinned = append(thinned, h)
+			}
+		}
+		hist = thinned
+	}
+	if p.Keep > 0 && len(hist) > p.Keep {
+		hist = hist[len(hist)-p.Keep:]
+	}
+	return hist
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var t0 = time.Date(2022, time.February, 20, 0, 0, 0, 0, time.UTC)
+
+func issuance(hours int, hash string) Issuance {
+	return Issuance{Issued: t0.Add(time.Duration(hours) * time.Hour), Hash: hash}
+}
+
+func issued(hist []Issuance) []int {
+	var hours []int
+	for _, h := range hist {
+		hours = append(hours, int(h.Issued.Sub(t0)/time.Hour))
+	}
+	return hours
+}
+
+func TestAdd(t *testing.T) {
+	t.Parallel()
+
+	var hist []Issuance
+	for i := 0; i < 5; i++ {
+		hist = Add(hist, issuance(i, string(rune('a'+i))), Policy{})
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, issued(hist))
+
+	// Same issue time replaces the existing issuance.
+	hist = Add(hist, issuance(2, "x"), Policy{})
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, issued(hist))
+	assert.Equal(t, "x", hist[2].Hash)
+
+	// Same data as the latest issuance is not added.
+	hist = Add(hist, issuance(5, "e"), Policy{})
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, issued(hist))
+
+	// An older issuance is inserted in order.
+	hist = Add(hist, issuance(-1, "z"), Policy{})
+	assert.Equal(t, []int{-1, 0, 1, 2, 3, 4}, issued(hist))
+}
+
+func TestAddKeep(t *testing.T) {
+	t.Parallel()
+
+	var hist []Issuance
+	for i := 0; i < 5; i++ {
+		hist = Add(hist, issuance(i, string(rune('a'+i))), Policy{Keep: 3})
+	}
+	assert.Equal(t, []int{2, 3, 4}, issued(hist))
+}
+
+func TestAddInterval(t *testing.T) {
+	t.Parallel()
+
+	var hist []Issuance
+	for i := 0; i < 14; i++ {
+		hist = Add(hist, issuance(i, string(rune('a'+i))), Policy{Interval: 6 * time.Hour})
+	}
+	// The latest issuance of each 6 hours.
+	assert.Equal(t, []int{5, 11, 13}, issued(hist))
+
+	hist = nil
+	for i := 0; i < 14; i++ {
+		hist = Add(hist, issuance(i, string(rune('a'+i))), Policy{Keep: 2, Interval: 6 * time.Hour})
+	}
+	assert.Equal(t, []int{11, 13}, issued(hist))
+}
@@ -1,29 +1,45 @@
 package ims
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/airsounds/data/fetch/retry"
 	"golang.org/x/net/html/charset"
 )
 
 const forecastPath = "https://ims.gov.il/sites/default/files/ims_data/xml_files/IMS_001.xml"
 
+// timezone is the timezone of the IMS forecast times.
+var timezone, _ = time.LoadLocation("Asia/Jerusalem")
+
 type ForecastTime struct {
 	time.Time
 }
 
 func (c *ForecastTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	const format = "2/1/2006 15:04 MST"
+	const format = "2/1/2006 15:04"
 	var v string
-	d.DecodeElement(&v, &start)
-	// The IMS timestamps are given in the format "2/1/2006 15:04 MST". The timezone used is
-	// always set to "UTC" where it is actually should be in IDT.
-	v = v[:len(v)-3] + "IDT"
-	parse, err := time.Parse(format, v)
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	// The IMS timestamps are given in the format "2/1/2006 15:04 UTC". The timezone suffix is
+	// always "UTC" where the time is actually the local time in Israel, which is either IST or
+	// IDT according to the daylight saving rules.
+	fields := strings.Fields(v)
+	if len(fields) == 3 {
+		fields = fields[:2]
+	}
+	if timezone == nil {
+		return fmt.Errorf("timezone Asia/Jerusalem is not available")
+	}
+	parse, err := time.ParseInLocation(format, strings.Join(fields, " "), timezone)
 	if err != nil {
 		return err
 	}
@@ -32,20 +48,72 @@ func (c *ForecastTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) erro
 	return nil
 }
 
+// fixRepeatedHour fixes the times of the hour that repeats at the end of daylight saving time.
+// The local time of both occurrences is parsed to the second occurrence, so the first of two
+// equal consecutive times is moved an hour back, if it has the same local time.
+func fixRepeatedHour(fs []HourlyForecast) {
+	for i := 0; i+1 < len(fs); i++ {
+		t := fs[i].Time.Time
+		if !t.Equal(fs[i+1].Time.Time) {
+			continue
+		}
+		earlier := t.Add(-time.Hour)
+		if earlier.In(timezone).Format("15:04") == t.In(timezone).Format("15:04") {
+			fs[i].Time.Time = earlier
+		}
+	}
+}
+
 type Forecast struct {
 	Name      string           `xml:"LocationMetaData>LocationName"`
 	Lat       float32          `xml:"LocationMetaData>LocationLatitude"`
 	Long      float32          `xml:"LocationMetaData>LocationLongitude"`
 	Elevation float32          `xml:"LocationMetaData>LocationHeight"`
 	Forecast  []HourlyForecast `xml:"LocationData>Forecast"`
+	// URL from which the forecast was fetched.
+	URL string `xml:"-" json:"-"`
 }
 
+// HourlyForecast is the forecast of a location for a single hour. Only the elements that were
+// seen in the IMS feed are typed fields.
 type HourlyForecast struct {
 	Time      ForecastTime `xml:"ForecastTime"`
 	Temp      float32      `xml:"Temperature"`
 	RelHum    float32      `xml:"RelativeHumidity"`
 	WindSpeed float32      `xml:"WindSpeed"`
 	WindDir   float32      `xml:"WindDirection"`
+	// Other holds the elements that are not decoded into the typed fields, by element name. An
+	// element becomes a typed field once its name is confirmed in the live feed with the -live
+	// test.
+	Other map[string]string `xml:"-" json:",omitempty"`
+}
+
+// element is an XML element that is not decoded into a typed field.
+type element struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+func (f *HourlyForecast) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// The fields type does not have the UnmarshalXML method, to decode the typed fields with
+	// the default decoding.
+	type fields HourlyForecast
+	var v struct {
+		fields
+		Unknown []element `xml:",any"`
+	}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*f = HourlyForecast(v.fields)
+	for _, e := range v.Unknown {
+		if f.Other == nil {
+			f.Other = map[string]string{}
+		}
+		f.Other[e.XMLName.Local] = strings.TrimSpace(e.Value)
+	}
+	return nil
 }
 
 type forecastResponse struct {
@@ -53,17 +121,28 @@ type forecastResponse struct {
 	Forecasts []Forecast `xml:"Location"`
 }
 
-func Predict() ([]Forecast, error) {
-	resp, err := http.Get(forecastPath)
+func Predict(ctx context.Context, client retry.Doer) ([]Forecast, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, forecastPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %s", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetching forecast: %s", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 
-	return predict(resp.Body)
+	fs, err := predict(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fs {
+		fs[i].URL = forecastPath
+	}
+	return fs, nil
 }
 
 func predict(r io.Reader) ([]Forecast, error) {
@@ -71,5 +150,11 @@ func predict(r io.Reader) ([]Forecast, error) {
 	d := xml.NewDecoder(r)
 	d.CharsetReader = charset.NewReaderLabel
 	err := d.Decode(&data)
-	return data.Forecasts, err
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range data.Forecasts {
+		fixRepeatedHour(f.Forecast)
+	}
+	return data.Forecasts, nil
 }
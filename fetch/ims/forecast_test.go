@@ -2,6 +2,13 @@ package ims
 
 import (
 	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,8 +18,21 @@ import (
 	_ "embed"
 )
 
-//go:embed testdata/forecast.xml
-var data []byte
+var (
+	live   = flag.Bool("live", false, "Test the decoding of the live IMS forecast")
+	update = flag.Bool("update", false, "With -live, store the live IMS forecast in testdata/forecast-live.xml")
+)
+
+var (
+	//go:embed testdata/forecast.xml
+	data []byte
+	//go:embed testdata/forecast-summer.xml
+	dataSummer []byte
+	//go:embed testdata/forecast-dst.xml
+	dataDST []byte
+	//go:embed testdata/forecast-dst-start.xml
+	dataDSTStart []byte
+)
 
 func TestForecast(t *testing.T) {
 	t.Parallel()
@@ -59,9 +79,139 @@ func TestForecast(t *testing.T) {
 	assert.Equal(t, 2022, gotTime.Year())
 	assert.Equal(t, time.February, gotTime.Month())
 	assert.Equal(t, 20, gotTime.Day())
-	assert.Equal(t, 15, gotTime.Hour()) // 18 IDT converted to UTC.
+	assert.Equal(t, 16, gotTime.Hour()) // 18 IST converted to UTC.
 	assert.Equal(t, 0, gotTime.Minute())
 	assert.Equal(t, 0, gotTime.Second())
 	assert.Equal(t, 0, gotTime.Nanosecond())
 	assert.Equal(t, time.UTC, gotTime.Location())
 }
+
+func TestForecastOther(t *testing.T) {
+	t.Parallel()
+
+	// Elements that are not typed fields are kept by name.
+	withOther := bytes.Replace(data, []byte("<WindDirection>294</WindDirection>"), []byte("<WindDirection>294</WindDirection><DewPointTemp> 17.6 </DewPointTemp>"), 1)
+	fs, err := predict(bytes.NewReader(withOther))
+	require.NoError(t, err)
+	f := fs[0].Forecast[0]
+	assert.Equal(t, float32(294), f.WindDir)
+	assert.Equal(t, map[string]string{"DewPointTemp": "17.6"}, f.Other)
+	assert.Nil(t, fs[0].Forecast[1].Other)
+
+	// The test forecast has only typed elements.
+	fs, err = predict(bytes.NewReader(data))
+	require.NoError(t, err)
+	for _, forecast := range fs {
+		for _, f := range forecast.Forecast {
+			require.Nil(t, f.Other, "%s at %s", forecast.Name, f.Time)
+		}
+	}
+}
+
+// TestLiveForecast verifies that the typed fields are decoded from the live IMS forecast, and
+// logs the names of the elements that are kept in Other, so they can be made typed fields.
+func TestLiveForecast(t *testing.T) {
+	if !*live {
+		t.Skip("The live IMS forecast is tested with -live")
+	}
+
+	resp, err := http.Get(forecastPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, ioutil.WriteFile("testdata/forecast-live.xml", body, 0644))
+	}
+
+	fs, err := predict(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NotEmpty(t, fs)
+
+	other := map[string]string{}
+	for _, forecast := range fs {
+		require.NotEmpty(t, forecast.Forecast, forecast.Name)
+		for _, f := range forecast.Forecast {
+			assert.False(t, f.Time.IsZero(), "%s: missing ForecastTime", forecast.Name)
+			for name, value := range f.Other {
+				other[name] = value
+			}
+		}
+	}
+	var names []string
+	for name, value := range other {
+		names = append(names, fmt.Sprintf("%s (e.g. %q)", name, value))
+	}
+	sort.Strings(names)
+	t.Logf("Elements in Other: %s", strings.Join(names, ", "))
+}
+
+func TestForecastTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data []byte
+		want []time.Time
+	}{
+		{
+			name: "summer",
+			data: dataSummer,
+			// IDT is UTC+3.
+			want: []time.Time{
+				time.Date(2022, time.July, 14, 9, 0, 0, 0, time.UTC),
+				time.Date(2022, time.July, 14, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "start of daylight saving time",
+			data: dataDSTStart,
+			// At 02:00 IST the clock moves forward to 03:00 IDT, so there is no 02:00 forecast.
+			want: []time.Time{
+				time.Date(2022, time.March, 24, 22, 0, 0, 0, time.UTC),
+				time.Date(2022, time.March, 24, 23, 0, 0, 0, time.UTC),
+				time.Date(2022, time.March, 25, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.March, 25, 1, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "end of daylight saving time",
+			data: dataDST,
+			// At 02:00 IDT the clock moves back to 01:00 IST, so 01:00 is repeated.
+			want: []time.Time{
+				time.Date(2022, time.October, 29, 21, 0, 0, 0, time.UTC),
+				time.Date(2022, time.October, 29, 22, 0, 0, 0, time.UTC),
+				time.Date(2022, time.October, 29, 23, 0, 0, 0, time.UTC),
+				time.Date(2022, time.October, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs, err := predict(bytes.NewReader(tt.data))
+			require.NoError(t, err)
+			require.Equal(t, 1, len(fs))
+
+			var got []time.Time
+			for _, f := range fs[0].Forecast {
+				got = append(got, f.Time.Time)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestForecastTimeInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"", "UT", "20/2/2022", "2022-02-20 18:00 UTC"} {
+		var ft ForecastTime
+		err := xml.Unmarshal([]byte("<ForecastTime>"+v+"</ForecastTime>"), &ft)
+		assert.Error(t, err, v)
+	}
+}
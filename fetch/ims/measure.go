@@ -1,12 +1,14 @@
 package ims
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/airsounds/data/fetch/retry"
 	"github.com/posener/tmplt"
 )
 
@@ -18,6 +20,8 @@ type Measurement struct {
 	RelHumid   float32
 	WindDir    float32
 	WindSpeed  float32
+	// URL from which the measurement was fetched.
+	URL string `json:"-"`
 }
 
 type Station int
@@ -40,7 +44,7 @@ type response struct {
 }
 
 // According to https://ims.gov.il/sites/default/files/%D7%A4%D7%A7%D7%95%D7%93%D7%95%D7%AA%20API.pdf
-func Measure(token string, station Station, date time.Time) ([]Measurement, error) {
+func Measure(ctx context.Context, client retry.Doer, token string, station Station, date time.Time) ([]Measurement, error) {
 	u, err := url.Execute(struct {
 		Station Station
 		Date    string
@@ -52,13 +56,13 @@ func Measure(token string, station Station, date time.Time) ([]Measurement, erro
 		return nil, fmt.Errorf("formatting url: %s", err)
 	}
 	log.Printf("Request URL: %s", u)
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %s", err)
 	}
 	req.Header.Set("Authorization", "ApiToken "+token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("perform request: %s", err)
 	}
@@ -81,7 +85,7 @@ func Measure(token string, station Station, date time.Time) ([]Measurement, erro
 			log.Printf("Skip time %s", d.DataTime)
 			continue
 		}
-		i := Measurement{Time: d.DataTime}
+		i := Measurement{Time: d.DataTime, URL: u}
 		for _, ch := range d.Channels {
 			switch ch.Name {
 			case "TG":
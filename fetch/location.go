@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/airsounds/data/fetch/uwyo"
+)
+
+// locationsVersion is the supported version of the locations file format.
+const locationsVersion = 1
+
+type Location struct {
+	Name string  `json:"name"`
+	Lat  float32 `json:"lat"`
+	Long float32 `json:"long"`
+	// Alt of the location in feet.
+	Alt       int `json:"alt"`
+	RunwayDir int `json:"runway_dir,omitempty"`
+	// MaxCrosswind is the crosswind limit in knots of the runway. If not set, the value of the
+	// -max-crosswind flag is used.
+	MaxCrosswind float32 `json:"max_crosswind,omitempty"`
+
+	// Per source identifiers of the location.
+
+	// IMSName is the location name in the IMS forecast. If not set, the nearest IMS forecast
+	// location is used.
+	IMSName string `json:"ims_name,omitempty"`
+	// IMSStation is the IMS Envista station of the location. Measurements are fetched only for
+	// locations that have a station.
+	IMSStation int `json:"ims_station,omitempty"`
+	// UWYOStation is the number of the radiosonde station that is used for the location. If not
+	// set, the nearest station is used.
+	UWYOStation int `json:"uwyo_station,omitempty"`
+}
+
+// locationsFile is the format of the locations file.
+type locationsFile struct {
+	Version   int        `json:"version"`
+	Locations []Location `json:"locations"`
+}
+
+// loadLocations loads and validates the locations file.
+func loadLocations(path string) ([]Location, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var content locationsFile
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	err = d.Decode(&content)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %s", path, err)
+	}
+	if content.Version != locationsVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d, expected %d", path, content.Version, locationsVersion)
+	}
+	err = validateLocations(content.Locations)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return content.Locations, nil
+}
+
+func validateLocations(locs []Location) error {
+	if len(locs) == 0 {
+		return fmt.Errorf("no locations")
+	}
+	names := map[string]bool{}
+	for i, loc := range locs {
+		if loc.Name == "" {
+			return fmt.Errorf("location #%d: missing name", i)
+		}
+		if names[loc.Name] {
+			return fmt.Errorf("location %s: duplicate name", loc.Name)
+		}
+		names[loc.Name] = true
+		if loc.Lat < -90 || loc.Lat > 90 {
+			return fmt.Errorf("location %s: latitude %v out of range", loc.Name, loc.Lat)
+		}
+		if loc.Long < -180 || loc.Long > 180 {
+			return fmt.Errorf("location %s: longitude %v out of range", loc.Name, loc.Long)
+		}
+		if loc.RunwayDir < 0 || loc.RunwayDir >= 360 {
+			return fmt.Errorf("location %s: runway direction %d out of range", loc.Name, loc.RunwayDir)
+		}
+		if loc.MaxCrosswind < 0 {
+			return fmt.Errorf("location %s: negative crosswind limit %v", loc.Name, loc.MaxCrosswind)
+		}
+		if loc.IMSStation < 0 {
+			return fmt.Errorf("location %s: invalid IMS station %d", loc.Name, loc.IMSStation)
+		}
+		if _, ok := uwyo.LookupStation(loc.UWYOStation); loc.UWYOStation != 0 && !ok {
+			return fmt.Errorf("location %s: unknown UWYO station %d", loc.Name, loc.UWYOStation)
+		}
+	}
+	return nil
+}
+
+// selectLocations returns the locations listed in a comma separated list of names. An empty list
+// selects all the locations.
+func selectLocations(names string) ([]Location, error) {
+	if strings.TrimSpace(names) == "" {
+		return locations, nil
+	}
+	var locs []Location
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, loc := range locations {
+			if loc.Name == name {
+				locs = append(locs, loc)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown location %q", name)
+		}
+	}
+	return locs, nil
+}
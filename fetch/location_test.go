@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadLocations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: `{"version": 1, "locations": [{"name": "megido", "lat": 32.6, "long": 35.2, "alt": 200, "runway_dir": 270, "uwyo_station": 40179}, {"name": "zefat", "lat": 32.9, "long": 35.5}]}`,
+		},
+		{
+			name:    "wrong version",
+			content: `{"version": 2, "locations": [{"name": "megido"}]}`,
+			wantErr: "unsupported version 2",
+		},
+		{
+			name:    "missing version",
+			content: `{"locations": [{"name": "megido"}]}`,
+			wantErr: "unsupported version 0",
+		},
+		{
+			name:    "unknown field",
+			content: `{"version": 1, "locations": [{"name": "megido", "latitude": 32.6}]}`,
+			wantErr: `unknown field "latitude"`,
+		},
+		{
+			name:    "invalid json",
+			content: `{"version": 1, "locations": [`,
+			wantErr: "decoding",
+		},
+		{
+			name:    "no locations",
+			content: `{"version": 1, "locations": []}`,
+			wantErr: "no locations",
+		},
+		{
+			name:    "missing name",
+			content: `{"version": 1, "locations": [{"lat": 32.6}]}`,
+			wantErr: "missing name",
+		},
+		{
+			name:    "duplicate name",
+			content: `{"version": 1, "locations": [{"name": "megido"}, {"name": "megido"}]}`,
+			wantErr: "location megido: duplicate name",
+		},
+		{
+			name:    "latitude out of range",
+			content: `{"version": 1, "locations": [{"name": "megido", "lat": 90.5}]}`,
+			wantErr: "latitude 90.5 out of range",
+		},
+		{
+			name:    "longitude out of range",
+			content: `{"version": 1, "locations": [{"name": "megido", "long": -181}]}`,
+			wantErr: "longitude -181 out of range",
+		},
+		{
+			name:    "runway direction out of range",
+			content: `{"version": 1, "locations": [{"name": "megido", "runway_dir": 360}]}`,
+			wantErr: "runway direction 360 out of range",
+		},
+		{
+			name:    "negative crosswind limit",
+			content: `{"version": 1, "locations": [{"name": "megido", "max_crosswind": -1}]}`,
+			wantErr: "negative crosswind limit",
+		},
+		{
+			name:    "invalid IMS station",
+			content: `{"version": 1, "locations": [{"name": "megido", "ims_station": -1}]}`,
+			wantErr: "invalid IMS station -1",
+		},
+		{
+			name:    "unknown UWYO station",
+			content: `{"version": 1, "locations": [{"name": "megido", "uwyo_station": 12345}]}`,
+			wantErr: "unknown UWYO station 12345",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "locations.json")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.content), 0644))
+
+			locs, err := loadLocations(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 2, len(locs))
+			assert.Equal(t, 40179, locs[0].UWYOStation)
+		})
+	}
+}
+
+// The locations file of the repository is valid.
+func TestLoadLocationsFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := loadLocations("../locations.json")
+	require.NoError(t, err)
+}
@@ -1,95 +1,195 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/airsounds/data/fetch/ims"
 	"github.com/airsounds/data/fetch/noaa"
-	"github.com/airsounds/data/fetch/uwyo"
+	"github.com/airsounds/data/fetch/retry"
 	"github.com/posener/goaction"
 	"github.com/posener/goaction/actionutil"
 )
 
 var (
 	//goaction:required
-	source = flag.String("source", "", "Which source to update")
+	source = flag.String("source", "", "Which source to update (comma separated, all if empty)")
+
+	locationsPath = flag.String("locations", "locations.json", "Path to the locations file, relative to the data directory")
+
+	workersFlag = flag.String("workers", "noaa=2", "Number of concurrent fetches per source, as a comma separated list of source=workers (default 1)")
+
+	httpTimeoutFlag = flag.String("http-timeout", "1m", "Timeout of a single HTTP request attempt")
+	httpAttempts    = flag.Int("http-attempts", 4, "Maximum number of attempts of an HTTP request")
+
+	failPolicy = flag.String("fail", "all", "When to exit with failure: never, any (any fetch failed), source (any source failed for all locations) or all (all sources failed)")
+
+	recoverMode = flag.String("recover", "git", "How to recover corrupt data files: abort, git (restore from git HEAD, quarantine if not possible) or quarantine")
+
+	maxCrosswindFlag = flag.String("max-crosswind", "10", "Crosswind limit in knots for locations that do not set one")
+
+	imsAll = flag.Bool("ims-all", false, "Store the IMS forecast of all the IMS locations, also those that are not in the locations file")
+
+	historyKeep         = flag.Int("history", 0, "Forecast history: number of latest issuances of each forecast to keep per hour (0 for no limit). The history is kept if -history or -history-interval is set")
+	historyIntervalFlag = flag.String("history-interval", "0", "Forecast history: keep only the latest issuance of a forecast in each interval, e.g. 6h (0 keeps all issuances)")
+
+	noaaModel   = flag.String("noaa-model", "GFS", "NOAA model to fetch: GFS, NAM, RAP, Op40 or HRRR")
+	noaaFcstLen = flag.String("noaa-fcst-len", "shortest", "NOAA forecast length: shortest (latest run), longest (earliest run) or forecast hour")
+)
+
+// The action inputs are declared in this file only, as goaction orders the inputs of different
+// files randomly.
+var (
+	//goaction:description Token for the IMS Envista API
+	imsToken = os.Getenv("IMS_API_TOKEN")
 )
 
 var timezone, _ = time.LoadLocation("Asia/Jerusalem")
 
-type Location struct {
-	Name        string  `json:"name"`
-	Lat         float32 `json:"lat"`
-	Long        float32 `json:"long"`
-	Alt         int     `json:"alt"`
-	UWYOStation int     `json:"uwyo_station"`
-	IMSName     string  `json:-`
-	RunwayDir   int     `json:"runway_dir,omitempty"`
+// defaultMaxCrosswind is the crosswind limit in knots of the -max-crosswind flag.
+var defaultMaxCrosswind float64
+
+// httpClient is used by all the sources to perform HTTP requests.
+var httpClient retry.Doer
+
+// locations are the locations for which data is fetched, loaded from the locations file.
+var locations []Location
+
+// Index is the content of the index.json file.
+type Index struct {
+	// The fields of the legacy index are kept for the consumers of the legacy index format. They
+	// are updated from Sources when the index is written.
+	legacyIndex
+	Sources   map[string]*SourceIndex
+	Locations []Location
+	// ExtraLocations are locations that are not in the locations file, for which sources store
+	// data, by their key in the day files.
+	ExtraLocations map[location]ExtraLocation `json:",omitempty"`
+	// Mappings are the source locations that are used for each location, by location name and
+	// then by source name.
+	Mappings map[string]map[string]Mapping `json:",omitempty"`
 }
 
-var locations = []Location{
-	{
-		Name:        "megido",
-		Lat:         32.597662,
-		Long:        35.234076,
-		Alt:         200,
-		IMSName:     "AFULA NIR HAEMEQ",
-		UWYOStation: 40179, // Bet Dagan
-		RunwayDir:   270,
-	},
-	{
-		Name:        "sde-teiman",
-		Lat:         31.287646,
-		Long:        34.722855,
-		Alt:         656,
-		IMSName:     "BEER SHEVA",
-		UWYOStation: 40179, // Bet Dagan
-	},
-	{
-		Name:        "zefat",
-		Lat:         32.965719,
-		Long:        35.497225,
-		Alt:         2559,
-		IMSName:     "ZEFAT HAR KENAAN",
-		UWYOStation: 40179, // Bet Dagan
-	},
-	{
-		Name:        "bet-shaan",
-		Lat:         32.102560,
-		Long:        35.197610,
-		Alt:         -394,
-		IMSName:     "EDEN FARM",
-		UWYOStation: 40179, // Bet Dagan
-	},
+// SourceIndex holds the time range of the data stored for a source and the status of its last
+// fetch.
+type SourceIndex struct {
+	Start, End time.Time
+	LastUpdate time.Time
+
+	// Status of the last fetch: "ok", "partial" if some of the locations failed, "failed" if all
+	// of them failed, or "skipped" if the source is not configured.
+	Status string `json:",omitempty"`
+	// LastError is the last fetch error, and LastErrorTime is the time in which it occurred.
+	LastError     string `json:",omitempty"`
+	LastErrorTime time.Time
 }
 
-var index struct {
-	NoaaStart, NoaaEnd time.Time
-	NoaaLastUpdate     time.Time
+// extend the index time range to include the given records.
+func (idx *SourceIndex) extend(records []Record) {
+	for _, r := range records {
+		idx.Start = timeMin(idx.Start, r.Time).In(timezone)
+		idx.End = timeMax(idx.End, r.Time).In(timezone)
+	}
+}
 
-	IMSStart, IMSEnd time.Time
-	IMSLastUpdate    time.Time
-	Locations        []Location
+// source returns the index of the given source, creating it if it does not exist.
+func (i *Index) source(name string) *SourceIndex {
+	if i.Sources == nil {
+		i.Sources = map[string]*SourceIndex{}
+	}
+	if i.Sources[name] == nil {
+		i.Sources[name] = &SourceIndex{}
+	}
+	return i.Sources[name]
+}
 
-	UWYOStart, UWYOEnd time.Time
+// legacyIndex is the index format used before sources were pluggable. It is used to migrate an
+// existing index.json, and is still written next to the sources index.
+type legacyIndex struct {
+	NoaaStart, NoaaEnd, NoaaLastUpdate time.Time
+	IMSStart, IMSEnd, IMSLastUpdate    time.Time
+	UWYOStart, UWYOEnd                 time.Time
 }
 
+// migrate the sources index from the legacy index, if it does not exist.
+func (i *Index) migrate() {
+	l := i.legacyIndex
+	if len(i.Sources) > 0 {
+		return
+	}
+	migrate := func(name string, start, end, lastUpdate time.Time) {
+		if (start == time.Time{}) {
+			return
+		}
+		*i.source(name) = SourceIndex{Start: start, End: end, LastUpdate: lastUpdate}
+	}
+	migrate("noaa", l.NoaaStart, l.NoaaEnd, l.NoaaLastUpdate)
+	migrate("ims", l.IMSStart, l.IMSEnd, l.IMSLastUpdate)
+	migrate("uwyo", l.UWYOStart, l.UWYOEnd, time.Time{})
+}
+
+// updateLegacy updates the legacy index from the sources index.
+func (i *Index) updateLegacy() {
+	get := func(name string) SourceIndex {
+		if idx := i.Sources[name]; idx != nil {
+			return *idx
+		}
+		return SourceIndex{}
+	}
+	noaa, ims, uwyo := get("noaa"), get("ims"), get("uwyo")
+	i.legacyIndex = legacyIndex{
+		NoaaStart:      noaa.Start,
+		NoaaEnd:        noaa.End,
+		NoaaLastUpdate: noaa.LastUpdate,
+		IMSStart:       ims.Start,
+		IMSEnd:         ims.End,
+		IMSLastUpdate:  ims.LastUpdate,
+		UWYOStart:      uwyo.Start,
+		UWYOEnd:        uwyo.End,
+	}
+}
+
+var index Index
+
 type hour int
 type location string
-type sources struct {
-	IMS  *ims.HourlyForecast `json:"ims"`
-	NOAA *noaa.NOAA          `json:"noaa"`
-	UWYO *uwyo.UWYO          `json:"uwyo"`
+
+// sources maps a source name to its data.
+type sources map[string]json.RawMessage
+
+// decode the data of the given source into v. It returns false if the data does not exist.
+func (s sources) decode(name string, v interface{}) bool {
+	raw, ok := s[name]
+	if !ok || string(raw) == "null" {
+		return false
+	}
+	err := json.Unmarshal(raw, v)
+	if err != nil {
+		log.Printf("Decode %s data: %v", name, err)
+		return false
+	}
+	return true
+}
+
+// set the data of the given source.
+func (s sources) set(name string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.Fatalf("Encode %s data: %v", name, err)
+	}
+	s[name] = raw
 }
 
-type dayData map[hour]map[location]*sources
+type dayData map[hour]map[location]sources
 
 const (
 	noaaForecast = 4 * 24 * time.Hour
@@ -103,133 +203,119 @@ var (
 
 func init() {
 	log.SetFlags(log.Lshortfile | log.Ltime)
-	flag.Parse()
 }
 
 func main() {
-	// List of modified files.
-	var modified []string
-
-	mustDecodeJson(indexPath, &index)
-	index.Locations = locations
-
-	if *source == "noaa" || *source == "" {
-		modified = append(modified, runNOAA()...)
+	// Flags are parsed in main and not in init, so that tests of the package can register and
+	// parse the testing flags.
+	flag.Parse()
+	srcs, err := selectSources(*source)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if *source == "ims" || *source == "" {
-		modified = append(modified, runIMS()...)
+	if !validRecoverMode(*recoverMode) {
+		log.Fatalf("Invalid recover mode %q", *recoverMode)
 	}
-
-	if *source == "uwyo" || *source == "" {
-		modified = append(modified, runUWYO()...)
+	if !validFailPolicy(*failPolicy) {
+		log.Fatalf("Invalid fail policy %q", *failPolicy)
 	}
-
-	mustEncodeJson(indexPath, index)
-	if len(modified) > 0 {
-		modified = append(modified, indexPath)
+	if !noaa.ValidModel(*noaaModel) {
+		log.Fatalf("Invalid NOAA model %q", *noaaModel)
+	}
+	if !noaa.ValidFcstLen(*noaaFcstLen) {
+		log.Fatalf("Invalid NOAA forecast length %q", *noaaFcstLen)
+	}
+	historyInterval, err = time.ParseDuration(*historyIntervalFlag)
+	if err != nil || *historyKeep < 0 || historyInterval < 0 {
+		log.Fatalf("Invalid forecast history policy: -history=%d -history-interval=%q", *historyKeep, *historyIntervalFlag)
+	}
+	httpTimeout, err := time.ParseDuration(*httpTimeoutFlag)
+	if err != nil || httpTimeout <= 0 {
+		log.Fatalf("Invalid -http-timeout %q, expected a positive duration such as 30s", *httpTimeoutFlag)
+	}
+	defaultMaxCrosswind, err = parseMaxCrosswind(*maxCrosswindFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workers, err := parseWorkers(*workersFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	locations, err = loadLocations(dataPath(*locationsPath))
+	if err != nil {
+		log.Fatalf("Loading locations: %s", err)
 	}
 
-	commit(modified)
-}
-
-func runNOAA() (paths []string) {
-	for _, loc := range locations {
-		ns, err := noaa.Get(startOfDay, startOfDay.Add(noaaForecast), loc.Lat, loc.Long)
-		if err != nil {
-			log.Fatalf("Fetching NOAA for %s: %s", loc.Name, err)
-		}
+	httpClient = retry.New(httpTimeout, *httpAttempts)
+	ctx, cancel := signalContext()
+	defer cancel()
 
-		for _, n := range ns {
-			n := n
-			path := addToDailyData(
-				n.Time,
-				location(loc.Name),
-				func(s *sources) { s.NOAA = n })
-			paths = append(paths, path)
-
-			// Update index
-			index.NoaaLastUpdate = time.Now().In(timezone)
-			index.NoaaStart = timeMin(index.NoaaStart, n.Time).In(timezone)
-			index.NoaaEnd = timeMax(index.NoaaEnd, n.Time).In(timezone)
-			log.Printf("Wrote NOAA forcast file %s", path)
-		}
+	if flag.Arg(0) == "backfill" {
+		backfill(ctx, flag.Args()[1:], workers)
+		return
 	}
-	return
-}
 
-func runIMS() (paths []string) {
-	var locationNames = map[string]string{}
-	for _, l := range locations {
-		locationNames[l.IMSName] = l.Name
-	}
-	imss, err := ims.Predict()
+	start, end := startOfDay, startOfDay.Add(noaaForecast)
+	err = checkFiles(append([]string{indexPath}, dayPaths(start, end)...), *recoverMode)
 	if err != nil {
-		log.Fatalf("Fetching IMS: %s", err)
+		log.Fatal(err)
 	}
-	for _, i := range imss {
-		locationName := locationNames[string(i.Name)]
-		if locationName == "" {
-			log.Printf("Skipping unmapped location: %q", i.Name)
-			continue
-		}
+	loadIndex()
 
-		for _, f := range i.Forecast {
-			f := f
-			path := addToDailyData(
-				f.Time.Time,
-				location(locationName),
-				func(s *sources) { s.IMS = &f })
-			paths = append(paths, path)
-
-			// Update index
-			index.IMSStart = timeMin(index.IMSStart, f.Time.Time).In(timezone)
-			index.IMSEnd = timeMax(index.IMSEnd, f.Time.Time).In(timezone)
-			log.Printf("Wrote IMS forcast file %s", path)
-		}
+	st := newStore()
+	statuses := run(ctx, st, srcs, locations, start, end, false, workers)
+	derive(st)
+	modified := st.flush()
+
+	// The index is always committed, as it holds the fetch statuses of the sources.
+	writeIndex()
+	modified = append(modified, indexPath)
+
+	commit(modified)
+
+	if shouldFail(*failPolicy, statuses) {
+		log.Printf("Failing according to %q policy, source statuses: %v", *failPolicy, statuses)
+		os.Exit(1)
 	}
+}
+
+// loadIndex loads the index file, migrating it from the legacy format if needed.
+func loadIndex() {
+	mustDecodeJson(indexPath, &index)
+	index.migrate()
+	index.Locations = locations
+	index.pruneMappings(locations)
+}
 
-	index.IMSLastUpdate = time.Now()
-	return
+// writeIndex writes the index file, with the legacy index fields.
+func writeIndex() {
+	index.updateLegacy()
+	mustEncodeJson(indexPath, index)
 }
 
-func runUWYO() (paths []string) {
-	for _, station := range collectStations() {
-		tables, err := uwyo.Fetch(station, time.Now())
-		if err != nil {
-			log.Fatalf("Fetching UWYO: %s", err)
-		}
-		for _, table := range tables {
-			table := table
-			path := addToDailyData(
-				table.Time,
-				location(strconv.Itoa(station)),
-				func(s *sources) { s.UWYO = table })
-			paths = append(paths, path)
-
-			// Update index
-			index.UWYOStart = timeMin(index.UWYOStart, table.Time).In(timezone)
-			index.UWYOEnd = timeMax(index.UWYOEnd, table.Time).In(timezone)
-			log.Printf("Wrote UWYO file %s", path)
+// signalContext returns a context that is canceled when the process is interrupted.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-sig:
+			log.Printf("Got %s, canceling", s)
+			cancel()
+		case <-ctx.Done():
 		}
-	}
-	return
+		signal.Stop(sig)
+	}()
+	return ctx, cancel
 }
 
-func addToDailyData(t time.Time, l location, assign func(*sources)) (path string) {
-	h := hour(t.Hour())
-	path = outputPath(t)
-
-	content := dayData{}
-	mustDecodeJson(path, &content)
-	if content[h] == nil {
-		content[h] = map[location]*sources{}
-	}
-	if content[h][l] == nil {
-		content[h][l] = &sources{}
+// dataPath returns a path relative to the data directory. Absolute paths are returned as is.
+func dataPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
 	}
-	assign(content[h][l])
-	mustEncodeJson(path, content)
-	return path
+	return filepath.Join(dataDir, path)
 }
 
 func outputPath(t time.Time) string {
@@ -237,34 +323,36 @@ func outputPath(t time.Time) string {
 }
 
 func mustDecodeJson(path string, data interface{}) {
+	err := decodeJson(path, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// decodeJson decodes a JSON file. A file that does not exist is not an error, and leaves data
+// unchanged.
+func decodeJson(path string, data interface{}) error {
 	f, err := os.Open(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return
+			return nil
 		}
-		log.Fatal(err)
+		return err
 	}
 	defer f.Close()
 	err = json.NewDecoder(f).Decode(data)
 	if err != nil {
-		log.Fatalf("Decode json %T: %v", data, err)
+		return fmt.Errorf("decode json %s to %T: %v", path, data, err)
 	}
+	return nil
 }
 
 func mustEncodeJson(path string, data interface{}) {
-	err := os.MkdirAll(filepath.Dir(path), 0755)
-	if err != nil {
-		log.Fatal(err)
-	}
-	f, err := os.Create(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
-
-	d := json.NewEncoder(f)
-	d.SetIndent("", " ")
-	err = d.Encode(data)
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		d := json.NewEncoder(w)
+		d.SetIndent("", " ")
+		return d.Encode(data)
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -311,15 +399,3 @@ func timeMax(a, b time.Time) time.Time {
 		return b
 	}
 }
-
-func collectStations() []int {
-	stationsSet := map[int]bool{}
-	for _, location := range locations {
-		stationsSet[location.UWYOStation] = true
-	}
-	var stations []int
-	for station := range stationsSet {
-		stations = append(stations, station)
-	}
-	return stations
-}
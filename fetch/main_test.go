@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexLegacy(t *testing.T) {
+	t.Parallel()
+
+	var idx Index
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"NoaaStart": "2020-07-17T12:00:00+03:00",
+		"NoaaEnd": "2024-10-03T21:00:00+03:00",
+		"NoaaLastUpdate": "2024-09-30T18:21:39+03:00",
+		"IMSStart": "2020-10-09T15:00:00+03:00",
+		"IMSEnd": "2024-10-04T03:00:00+03:00",
+		"IMSLastUpdate": "2024-09-30T19:24:40Z",
+		"UWYOStart": "2021-01-01T02:00:00+02:00",
+		"UWYOEnd": "2024-09-30T15:00:00+03:00"
+	}`), &idx))
+
+	// The sources index is migrated from the legacy index.
+	idx.migrate()
+	require.NotNil(t, idx.Sources["noaa"])
+	assert.True(t, idx.Sources["noaa"].End.Equal(time.Date(2024, time.October, 3, 18, 0, 0, 0, time.UTC)))
+	require.NotNil(t, idx.Sources["uwyo"])
+
+	// The legacy index is written from the sources index.
+	end := time.Date(2024, time.October, 5, 0, 0, 0, 0, time.UTC)
+	idx.Sources["noaa"].End = end
+	idx.updateLegacy()
+	raw, err := json.Marshal(idx)
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &got))
+	assert.Equal(t, end.Format(time.RFC3339), got["NoaaEnd"])
+	assert.Equal(t, "2020-10-09T15:00:00+03:00", got["IMSStart"])
+	assert.Contains(t, got, "UWYOEnd")
+	assert.Contains(t, got, "Sources")
+}
+
+func TestDataPath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "locations.json", dataPath("locations.json"))
+	assert.Equal(t, "conf/locations.json", dataPath("conf/locations.json"))
+	assert.Equal(t, "/etc/locations.json", dataPath("/etc/locations.json"))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/airsounds/data/fetch/geo"
+	"github.com/airsounds/data/fetch/ims"
+	"github.com/airsounds/data/fetch/uwyo"
+)
+
+// Mapping is the location of a source that is used for a location.
+type Mapping struct {
+	// ID of the location in the source.
+	ID string
+	// Auto is true if the mapping was selected automatically as the nearest source location, and
+	// false if it was set in the locations file.
+	Auto bool
+	// Distance in km between the location and the source location.
+	Distance float64
+	// ElevationDiff is the elevation of the source location above the location in meters.
+	ElevationDiff float64
+}
+
+// mappingsMu protects the mappings of the index, that are set by concurrent fetches.
+var mappingsMu sync.Mutex
+
+// setMapping records the mapping of a location to a source location in the index.
+func (idx *Index) setMapping(loc Location, src string, m Mapping) {
+	mappingsMu.Lock()
+	defer mappingsMu.Unlock()
+	if idx.Mappings == nil {
+		idx.Mappings = map[string]map[string]Mapping{}
+	}
+	if idx.Mappings[loc.Name] == nil {
+		idx.Mappings[loc.Name] = map[string]Mapping{}
+	}
+	if old, ok := idx.Mappings[loc.Name][src]; !ok || old.ID != m.ID {
+		log.Printf("Location %s is mapped to %s location %q (auto: %t, distance: %.1f km, elevation difference: %.0f m)",
+			loc.Name, src, m.ID, m.Auto, m.Distance, m.ElevationDiff)
+	}
+	idx.Mappings[loc.Name][src] = m
+}
+
+// pruneMappings removes the mappings of locations that are not in the locations file.
+func (idx *Index) pruneMappings(locs []Location) {
+	names := map[string]bool{}
+	for _, loc := range locs {
+		names[loc.Name] = true
+	}
+	for name := range idx.Mappings {
+		if !names[name] {
+			delete(idx.Mappings, name)
+		}
+	}
+}
+
+// point returns the point of a location, with the altitude converted from feet to meters.
+func (loc Location) point() geo.Point {
+	return geo.Point{Lat: float64(loc.Lat), Long: float64(loc.Long), Alt: float64(loc.Alt) / 3.28084}
+}
+
+// newMapping returns the mapping of a location to a source location at the given point.
+func newMapping(loc Location, id string, auto bool, p geo.Point) Mapping {
+	lp := loc.point()
+	return Mapping{
+		ID:   id,
+		Auto: auto,
+		// Rounded, so the index does not change between runs due to float noise.
+		Distance:      math.Round(geo.Distance(lp, p)*10) / 10,
+		ElevationDiff: math.Round(p.Alt - lp.Alt),
+	}
+}
+
+// uwyoStation returns the radiosonde station of a location: the station that is set in the
+// locations file, or the nearest station.
+func uwyoStation(loc Location) (int, Mapping) {
+	if loc.UWYOStation != 0 {
+		s, _ := uwyo.LookupStation(loc.UWYOStation)
+		return loc.UWYOStation, newMapping(loc, strconv.Itoa(s.Number), false, stationPoint(s))
+	}
+	var points []geo.Point
+	for _, s := range uwyo.Stations {
+		points = append(points, stationPoint(s))
+	}
+	s := uwyo.Stations[geo.Nearest(loc.point(), points)]
+	return s.Number, newMapping(loc, strconv.Itoa(s.Number), true, stationPoint(s))
+}
+
+func stationPoint(s uwyo.StationInfo) geo.Point {
+	return geo.Point{Lat: float64(s.Lat), Long: float64(s.Long), Alt: float64(s.Alt)}
+}
+
+// imsForecast returns the IMS forecast of a location: the forecast of the IMS location that is
+// set in the locations file, or of the nearest IMS location. It returns false if there is no
+// matching forecast.
+func imsForecast(loc Location, forecasts []ims.Forecast) (ims.Forecast, Mapping, bool) {
+	var points []geo.Point
+	for _, f := range forecasts {
+		points = append(points, forecastPoint(f))
+	}
+	if loc.IMSName != "" {
+		for i, f := range forecasts {
+			if f.Name == loc.IMSName {
+				return f, newMapping(loc, f.Name, false, points[i]), true
+			}
+		}
+		return ims.Forecast{}, Mapping{}, false
+	}
+	i := geo.Nearest(loc.point(), points)
+	if i == -1 {
+		return ims.Forecast{}, Mapping{}, false
+	}
+	f := forecasts[i]
+	return f, newMapping(loc, f.Name, true, points[i]), true
+}
+
+func forecastPoint(f ims.Forecast) geo.Point {
+	return geo.Point{Lat: float64(f.Lat), Long: float64(f.Long), Alt: float64(f.Elevation)}
+}
@@ -2,75 +2,289 @@ package noaa
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/airsounds/data/fetch/retry"
 	"github.com/posener/tmplt"
 )
 
-var url = tmplt.Text("https://rucsoundings.noaa.gov/get_soundings.cgi?data_source=GFS&start_year={{.Start.Year}}&start_month_name={{.Start.Month}}&start_mday={{.Start.Day}}&start_hour=0&start_min=0&n_hrs=1.0&fcst_len=shortest&airport={{.Lat}}%2C{{.Long}}&text=Ascii%20text%20%28GSD%20format%29&hydrometeors=false&startSecs={{.Start.Unix}}&endSecs={{.End.Unix}}")
+var url = tmplt.Text("https://rucsoundings.noaa.gov/get_soundings.cgi?data_source={{.Model}}&start_year={{.Start.Year}}&start_month_name={{.Start.Month}}&start_mday={{.Start.Day}}&start_hour=0&start_min=0&n_hrs=1.0&fcst_len={{.FcstLen}}&airport={{.Lat}}%2C{{.Long}}&text=Ascii%20text%20%28GSD%20format%29&hydrometeors=false&startSecs={{.Start.Unix}}&endSecs={{.End.Unix}}")
+
+// Models are the models that can be fetched, and the interval between their forecasts.
+var models = map[string]time.Duration{
+	"GFS":  3 * time.Hour,
+	"NAM":  time.Hour,
+	"RAP":  time.Hour,
+	"Op40": time.Hour,
+	"HRRR": time.Hour,
+}
+
+// ValidModel returns true if the model can be fetched.
+func ValidModel(model string) bool {
+	_, ok := models[model]
+	return ok
+}
+
+// ValidFcstLen returns true if the forecast length is "shortest", "longest" or a number of hours.
+func ValidFcstLen(fcstLen string) bool {
+	if fcstLen == "shortest" || fcstLen == "longest" {
+		return true
+	}
+	h, err := strconv.Atoi(fcstLen)
+	return err == nil && h >= 0
+}
+
+// Schema is the version of the NOAA data format. Version 1 (or a missing version in old data
+// files) stored the pressure, height, temperature and dew point truncated to integers, version 2
+// stores them in full precision, version 3 stores missing values as null, and version 4 has
+// only the mandatory and surface levels in the profile.
+const Schema = 4
+
+// missing is the value of missing data in the GSD format.
+const missing = 99999
 
 // NOAA forcast information.
+//
+// All the level columns are aligned, the i'th value of each column belongs to the i'th level of
+// the forecast. Levels without pressure or height are dropped, other values that are missing
+// from the forecast are nil.
 type NOAA struct {
+	// Schema is the version of the data format.
+	Schema int `json:",omitempty"`
 	// Time of Forecast
 	Time time.Time
+	// Model that produced the forecast.
+	Model string `json:",omitempty"`
+	// Run is the initialization time of the model run. It is nil for synthetic forecasts that
+	// are interpolated between different runs.
+	Run *time.Time `json:",omitempty"`
 	// Pressure in hPa
-	Pressure []int
+	Pressure []float32
 	// Height in feet
-	Height []int
+	Height []float32
 	// Temp in Deg C
-	Temp []int
+	Temp []*float32
 	// Dew point in deg C
-	Dew []int
+	Dew []*float32
 	// WindDir in degrees
-	WindDir []int
+	WindDir []*int
 	// WindSpeed in knots
-	WindSpeed []int
+	WindSpeed []*int
+	// Synthetic is true for forecasts that were interpolated between the GFS forecast hours.
+	Synthetic bool `json:",omitempty"`
+
+	// CAPE is the convective available potential energy in J/kg.
+	CAPE *float32 `json:",omitempty"`
+	// CIN is the convective inhibition in J/kg.
+	CIN *float32 `json:",omitempty"`
+	// Helic is the storm relative helicity in m^2/s^2.
+	Helic *float32 `json:",omitempty"`
+	// PW is the precipitable water in mm.
+	PW *float32 `json:",omitempty"`
+
+	// Surface is the surface level, which is also included in the profile.
+	Surface *Level `json:",omitempty"`
+	// Tropopause is the tropopause level.
+	Tropopause *Level `json:",omitempty"`
+	// MaxWind is the level of the maximum wind.
+	MaxWind *Level `json:",omitempty"`
+
+	// URL from which the forecast was fetched.
+	URL string `json:"-"`
+
+	// windScale converts the wind speed units of the forecast to knots.
+	windScale float64
+}
+
+// Level is a single level of a forecast. Missing values are nil.
+type Level struct {
+	// Pressure in hPa
+	Pressure *float32 `json:",omitempty"`
+	// Height in feet
+	Height *float32 `json:",omitempty"`
+	// Temp in Deg C
+	Temp *float32 `json:",omitempty"`
+	// Dew point in deg C
+	Dew *float32 `json:",omitempty"`
+	// WindDir in degrees
+	WindDir *int `json:",omitempty"`
+	// WindSpeed in knots
+	WindSpeed *int `json:",omitempty"`
+}
+
+// Line types of the GSD format, see https://rucsoundings.noaa.gov/raob_format.html.
+const (
+	lineIdentification = 1
+	lineChecks         = 2
+	lineStation        = 3
+	lineMandatory      = 4
+	lineSignificant    = 5
+	lineWind           = 6
+	lineTropopause     = 7
+	lineMaxWind        = 8
+	lineSurface        = 9
+)
+
+// parseLine parses a line of the forecast according to its line type.
+func (n *NOAA) parseLine(fields []string) error {
+	if fields[0] == "CAPE" {
+		return n.parseIndices(fields)
+	}
+	lineType, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return fmt.Errorf("invalid line type %q", fields[0])
+	}
+	switch lineType {
+	case lineStation:
+		// The last field is the wind speed units.
+		switch units := fields[len(fields)-1]; units {
+		case "kt":
+			n.windScale = 1
+		case "ms":
+			n.windScale = 3600 / 1852.0
+		default:
+			return fmt.Errorf("unknown wind speed units %q", units)
+		}
+	case lineMandatory, lineSurface, lineTropopause, lineMaxWind:
+		l, err := n.parseLevel(fields)
+		if err != nil {
+			return err
+		}
+		switch lineType {
+		case lineSurface:
+			n.Surface = l
+			n.appendLevel(l)
+		case lineMandatory:
+			n.appendLevel(l)
+		case lineTropopause:
+			n.Tropopause = l
+		case lineMaxWind:
+			n.MaxWind = l
+		}
+	case lineIdentification, lineChecks, lineSignificant, lineWind:
+		// Not used.
+	default:
+		return fmt.Errorf("unknown line type %d", lineType)
+	}
+	return nil
 }
 
-func (n *NOAA) appendFields(fields []string) error {
-	if err := appendInt(&n.Pressure, fields[1], 0.1); err != nil {
-		return err
+// parseIndices parses the line "CAPE <v> CIN <v> Helic <v> PW <v>".
+func (n *NOAA) parseIndices(fields []string) error {
+	if len(fields)%2 != 0 {
+		return fmt.Errorf("expected name value pairs")
 	}
-	if err := appendInt(&n.Height, fields[2], 3.28084 /* m to ft */); err != nil {
-		return err
+	for i := 0; i < len(fields); i += 2 {
+		v, err := parseValue(fields[i+1], 1)
+		if err != nil {
+			return fmt.Errorf("%s: %s", fields[i], err)
+		}
+		switch fields[i] {
+		case "CAPE":
+			n.CAPE = v
+		case "CIN":
+			n.CIN = v
+		case "Helic":
+			n.Helic = v
+		case "PW":
+			n.PW = v
+		}
 	}
-	if err := appendInt(&n.Temp, fields[3], 0.1); err != nil {
-		return err
+	return nil
+}
+
+// parseLevel parses a level line: type, pressure (tenths of hPa), height (m), temperature
+// (tenths of deg C), dew point (tenths of deg C), wind direction and wind speed.
+func (n *NOAA) parseLevel(fields []string) (*Level, error) {
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("expected 7 fields, got %d", len(fields))
 	}
-	if err := appendInt(&n.Dew, fields[4], 0.1); err != nil {
-		return err
+	windScale := n.windScale
+	if windScale == 0 {
+		windScale = 1
 	}
-	if err := appendInt(&n.WindDir, fields[5], 1); err != nil {
-		return err
+	var (
+		values = make([]*float32, 6)
+		scales = []float64{0.1, 3.28084 /* m to ft */, 0.1, 0.1, 1, windScale}
+	)
+	for i := range values {
+		v, err := parseValue(fields[i+1], scales[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
 	}
-	if err := appendInt(&n.WindSpeed, fields[6], 1); err != nil {
-		return err
+	return &Level{
+		Pressure:  values[0],
+		Height:    values[1],
+		Temp:      values[2],
+		Dew:       values[3],
+		WindDir:   toInt(values[4]),
+		WindSpeed: toInt(values[5]),
+	}, nil
+}
+
+// appendLevel appends a level to the profile. Levels without pressure or height are dropped.
+func (n *NOAA) appendLevel(l *Level) {
+	if l.Pressure == nil || l.Height == nil {
+		return
+	}
+	n.Pressure = append(n.Pressure, *l.Pressure)
+	n.Height = append(n.Height, *l.Height)
+	n.Temp = append(n.Temp, l.Temp)
+	n.Dew = append(n.Dew, l.Dew)
+	n.WindDir = append(n.WindDir, l.WindDir)
+	n.WindSpeed = append(n.WindSpeed, l.WindSpeed)
+}
+
+// validate checks that all the level columns are aligned.
+func (n *NOAA) validate() error {
+	levels := len(n.Pressure)
+	for _, l := range []int{len(n.Height), len(n.Temp), len(n.Dew), len(n.WindDir), len(n.WindSpeed)} {
+		if l != levels {
+			return fmt.Errorf("forecast of %s has columns of different lengths", n.Time)
+		}
 	}
 	return nil
 }
 
-func GetDate(date time.Time, lat, long float32) ([]*NOAA, error) {
+func GetDate(ctx context.Context, client retry.Doer, model, fcstLen string, date time.Time, lat, long float32) ([]*NOAA, error) {
 	// Set date to point on beginning of day.
 	start := date.Truncate(24 * time.Hour)
 	end := start.Add(24 * time.Hour)
-	return Get(start, end, lat, long)
+	return Get(ctx, client, model, fcstLen, start, end, lat, long)
 }
 
-func Get(start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
-	// Time must be a multiple of 3 hours.
-	start = start.Truncate(3 * time.Hour)
-	end = end.Truncate(3 * time.Hour)
+// Get returns the forecasts of the given model between start and end. The forecast length is
+// "shortest" for the latest run, "longest" for the earliest run, or the forecast hour.
+func Get(ctx context.Context, client retry.Doer, model, fcstLen string, start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
+	step, ok := models[model]
+	if !ok {
+		return nil, fmt.Errorf("unknown model %q", model)
+	}
+	if !ValidFcstLen(fcstLen) {
+		return nil, fmt.Errorf("invalid forecast length %q", fcstLen)
+	}
+	// Time must be a multiple of the model forecasts interval.
+	start = start.Truncate(step)
+	end = end.Truncate(step)
 
 	u, err := url.Execute(struct {
-		Start, End time.Time
-		Lat, Long  float32
+		Model, FcstLen string
+		Start, End     time.Time
+		Lat, Long      float32
 	}{
+		Model:   model,
+		FcstLen: fcstLen,
 		// NOAA expects time in UTC.
 		Start: start.UTC(),
 		End:   end.UTC(),
@@ -81,7 +295,11 @@ func Get(start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
 		return nil, err
 	}
 	log.Printf("Fetching NOAA with URL: %s", u)
-	resp, err := http.Get(u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -90,19 +308,33 @@ func Get(start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
 		return nil, fmt.Errorf("bad status code: %d", code)
 	}
 
-	// Valid output is in the format
-	//
-	// 	GFS 09 h forecast valid for grid point 13.1 nm / 243 deg from 32.6,35.23:
-	// 	GFS         21      19      Jun    2020
-	// 	   CAPE    231    CIN     -7  Helic  99999     PW     27
-	// 	      1  23062  99999  32.50 -35.00  99999  99999
-	// 	      2  99999  99999  99999     35  99999  99999
-	// 	      3           32.6,35.23            12     kt
-	// 	      9  10000     77    225    181    260      9
-	// 	      4   9750    297    209    171    265     13
+	ns, err := parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	ns, err = interpolateMissingHours(ns)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range ns {
+		n.URL = u
+	}
+	return ns, nil
+}
 
+// parse parses forecasts in the GSD format, that are in the format
+//
+//	GFS 09 h forecast valid for grid point 13.1 nm / 243 deg from 32.6,35.23:
+//	GFS         21      19      Jun    2020
+//	   CAPE    231    CIN     -7  Helic  99999     PW     27
+//	      1  23062  99999  32.50 -35.00  99999  99999
+//	      2  99999  99999  99999     35  99999  99999
+//	      3           32.6,35.23            12     kt
+//	      9  10000     77    225    181    260      9
+//	      4   9750    297    209    171    265     13
+func parse(r io.Reader) ([]*NOAA, error) {
 	var (
-		scanner = bufio.NewScanner(resp.Body)
+		scanner = bufio.NewScanner(r)
 		ns      []*NOAA
 	)
 	for scanner.Scan() {
@@ -110,21 +342,24 @@ func Get(start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
 		if len(strings.TrimSpace(line)) == 0 {
 			continue
 		}
-		if forecastHeader1.MatchString(line) {
+		if header := forecastHeader1.FindStringSubmatch(line); header != nil {
 			scanner.Scan()
 			t, err := parseTimeLine(scanner.Text())
 			if err != nil {
 				return nil, fmt.Errorf("failed parsing time header %q: %s", line, err)
 			}
-			log.Printf("Found forecast for time: %s", t)
+			var hours int
+			if header[3] != "" {
+				hours, _ = strconv.Atoi(header[3])
+			}
+			run := t.Add(-time.Duration(hours) * time.Hour)
+			log.Printf("Found %s forecast for time: %s (run %s)", header[1], t, run)
 			ns = append(ns, &NOAA{
-				Time: t,
+				Schema: Schema,
+				Time:   t,
+				Model:  header[1],
+				Run:    &run,
 			})
-
-			scanner.Scan() // Skip CAPE line
-			scanner.Scan() // Skip garbage line
-			scanner.Scan() // Skip garbage line
-			scanner.Scan() // Skip coords line
 			continue
 		}
 		if len(ns) == 0 {
@@ -133,67 +368,187 @@ func Get(start time.Time, end time.Time, lat, long float32) ([]*NOAA, error) {
 		// Update the last forecast item.
 		n := ns[len(ns)-1]
 		fields := strings.Fields(line)
-		if err := n.appendFields(fields); err != nil {
-			return nil, fmt.Errorf("failed loading fields %q: %s", fields, err)
+		if err := n.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("failed parsing line %q: %s", line, err)
 		}
 	}
-	ns = interpolateMissingHours(ns)
-	return ns, scanner.Err()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ns, nil
 }
 
-func appendInt(a *[]int, s string, scale float32) error {
+// parseValue parses a GSD value. It returns nil for missing values.
+func parseValue(s string, scale float64) (*float32, error) {
 	v, err := strconv.Atoi(s)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	*a = append(*a, int(float32(v)*scale))
-	return nil
+	if v == missing {
+		return nil, nil
+	}
+	// Computed in float64 and converted once, so that scaled values do not gain float32 noise.
+	f := float32(float64(v) * scale)
+	return &f, nil
 }
 
-// Matches regexp headers:
-//  GFS analysis valid for grid point 13.1 nm / 243 deg from 32.6,35.23:
-//  GFS         0      20      Jun    2020
+func toInt(f *float32) *int {
+	if f == nil {
+		return nil
+	}
+	i := int(math.Round(float64(*f)))
+	return &i
+}
+
+// Matches regexp headers of any model, for example:
+//
+//	GFS analysis valid for grid point 13.1 nm / 243 deg from 32.6,35.23:
+//	GFS 09 h forecast valid for grid point 13.1 nm / 243 deg from 32.6,35.23:
+//	GFS         0      20      Jun    2020
 var (
-	forecastHeader1 = regexp.MustCompile("^GFS .* for grid point")
-	forecastHeader2 = regexp.MustCompile(`^GFS\s+(\d+)\s+(\d+)\s+(\w+)\s+(\d+)$`)
+	forecastHeader1 = regexp.MustCompile(`^(\S+)\s+(analysis|(\d+) h forecast) valid .*for grid point`)
+	forecastHeader2 = regexp.MustCompile(`^\S+\s+(\d+)\s+(\d+)\s+(\w+)\s+(\d+)$`)
 )
 
 func parseTimeLine(line string) (time.Time, error) {
-	timeStr := strings.Join(forecastHeader2.FindStringSubmatch(line)[1:], " ")
+	m := forecastHeader2.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid time line %q", line)
+	}
+	timeStr := strings.Join(m[1:], " ")
 	return time.Parse("15 2 Jan 2006", timeStr)
 }
 
-func interpolateMissingHours(values []*NOAA) []*NOAA {
+// interpolateMissingHours adds hourly forecasts between the forecasts of the given values.
+func interpolateMissingHours(values []*NOAA) ([]*NOAA, error) {
 	if len(values) == 0 {
-		return nil
+		return nil, nil
+	}
+	for _, v := range values {
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
 	}
 	out := []*NOAA{values[0]}
-	for _, next := range values {
+	for _, next := range values[1:] {
 		last := out[len(out)-1]
+		if !next.Time.After(last.Time) {
+			return nil, fmt.Errorf("forecast of %s is not after forecast of %s", next.Time, last.Time)
+		}
 		for t := last.Time.Add(time.Hour); t.Before(next.Time); t = t.Add(time.Hour) {
-			r := float64(t.Hour()-last.Time.Hour()) / float64(next.Time.Hour()-last.Time.Hour())
-			out = append(out, &NOAA{
-				Time:      t,
-				Pressure:  interpolate(r, last.Pressure, next.Pressure),
-				Height:    interpolate(r, last.Height, next.Height),
-				Temp:      interpolate(r, last.Temp, next.Temp),
-				Dew:       interpolate(r, last.Dew, next.Dew),
-				WindDir:   interpolate(r, last.WindDir, next.WindDir),
-				WindSpeed: interpolate(r, last.WindSpeed, next.WindSpeed),
-			})
+			r := float64(t.Sub(last.Time)) / float64(next.Time.Sub(last.Time))
+			out = append(out, interpolateHour(t, r, last, next))
 		}
 		out = append(out, next)
 	}
-	return out
+	return out, nil
+}
+
+// interpolateHour returns the forecast at time t, that is at ratio r between the last and next
+// forecasts. The values are interpolated on the pressure levels of the last forecast, since the
+// two forecasts may have different levels. Levels that are outside the pressure range of the
+// next forecast are dropped.
+func interpolateHour(t time.Time, r float64, last, next *NOAA) *NOAA {
+	n := &NOAA{
+		Schema:    Schema,
+		Time:      t,
+		Model:     last.Model,
+		Synthetic: true,
+	}
+	if last.Run != nil && next.Run != nil && last.Run.Equal(*next.Run) {
+		n.Run = last.Run
+	}
+	for i, p := range last.Pressure {
+		lo, hi, w, ok := next.level(p)
+		if !ok {
+			continue
+		}
+		nextHeight := lerp(w, float64(next.Height[lo]), float64(next.Height[hi]))
+		n.Pressure = append(n.Pressure, p)
+		n.Height = append(n.Height, float32(lerp(r, float64(last.Height[i]), nextHeight)))
+		n.Temp = append(n.Temp, lerpValue(r, last.Temp[i], levelValue(next.Temp, lo, hi, w)))
+		n.Dew = append(n.Dew, lerpValue(r, last.Dew[i], levelValue(next.Dew, lo, hi, w)))
+
+		var dir, speed *int
+		u1, v1, ok1 := last.wind(i, i, 0)
+		u2, v2, ok2 := next.wind(lo, hi, w)
+		if ok1 && ok2 {
+			dir, speed = windFromVector(lerp(r, u1, u2), lerp(r, v1, v2))
+		}
+		n.WindDir = append(n.WindDir, dir)
+		n.WindSpeed = append(n.WindSpeed, speed)
+	}
+	return n
 }
 
-func interpolate(r float64, x1 []int, x2 []int) []int {
-	if len(x1) != len(x2) {
-		panic("not equal len")
+// level returns the levels lo and hi that the pressure p is between, and the weight of hi in the
+// log-pressure interpolation between them. It returns false if p is out of the forecast levels.
+func (n *NOAA) level(p float32) (lo, hi int, w float64, ok bool) {
+	for i := range n.Pressure {
+		if n.Pressure[i] == p {
+			return i, i, 0, true
+		}
+		if i == 0 {
+			continue
+		}
+		p0, p1 := n.Pressure[i-1], n.Pressure[i]
+		if (p0 < p && p < p1) || (p1 < p && p < p0) {
+			w := math.Log(float64(p/p0)) / math.Log(float64(p1/p0))
+			return i - 1, i, w, true
+		}
 	}
-	ret := make([]int, len(x1))
-	for i := range x1 {
-		ret[i] = x1[i] + int(r*float64(x2[i]-x1[i]))
+	return 0, 0, 0, false
+}
+
+// wind returns the wind vector interpolated between levels lo and hi with weight w of hi.
+func (n *NOAA) wind(lo, hi int, w float64) (u, v float64, ok bool) {
+	if n.WindDir[lo] == nil || n.WindSpeed[lo] == nil || n.WindDir[hi] == nil || n.WindSpeed[hi] == nil {
+		return 0, 0, false
 	}
-	return ret
+	u1, v1 := windVector(*n.WindDir[lo], *n.WindSpeed[lo])
+	u2, v2 := windVector(*n.WindDir[hi], *n.WindSpeed[hi])
+	return lerp(w, u1, u2), lerp(w, v1, v2), true
+}
+
+// windVector returns the u (east) and v (north) components of the wind. The direction is the
+// direction the wind is blowing from.
+func windVector(dir, speed int) (u, v float64) {
+	rad := float64(dir) * math.Pi / 180
+	return -float64(speed) * math.Sin(rad), -float64(speed) * math.Cos(rad)
+}
+
+// windFromVector returns the direction and speed of a wind vector. Interpolating the wind as a
+// vector handles directions around north correctly.
+func windFromVector(u, v float64) (dir, speed *int) {
+	s := int(math.Round(math.Hypot(u, v)))
+	d := 0
+	if s != 0 {
+		d = (int(math.Round(math.Atan2(-u, -v)*180/math.Pi)) + 360) % 360
+	}
+	return &d, &s
+}
+
+// levelValue returns the value interpolated between levels lo and hi with weight w of hi.
+func levelValue(x []*float32, lo, hi int, w float64) *float32 {
+	if lo == hi {
+		return x[lo]
+	}
+	if x[lo] == nil || x[hi] == nil {
+		return nil
+	}
+	v := float32(lerp(w, float64(*x[lo]), float64(*x[hi])))
+	return &v
+}
+
+// lerpValue interpolates between two values that may be missing.
+func lerpValue(r float64, x1, x2 *float32) *float32 {
+	if x1 == nil || x2 == nil {
+		return nil
+	}
+	v := float32(lerp(r, float64(*x1), float64(*x2)))
+	return &v
+}
+
+func lerp(r float64, x1, x2 float64) float64 {
+	return x1 + r*(x2-x1)
 }
@@ -0,0 +1,233 @@
+package noaa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	update = flag.Bool("update", false, "Update golden files, and with -live store the live NOAA response in testdata/forecast-live.txt")
+	live   = flag.Bool("live", false, "Test the parsing of a live NOAA forecast")
+)
+
+// testdata/forecast.txt is written in the format of the NOAA responses, but it is not a captured
+// response. TestLiveGet tests the parsing of a live response.
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("testdata/forecast.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ns, err := parse(f)
+	require.NoError(t, err)
+
+	got, err := json.MarshalIndent(ns, "", "  ")
+	require.NoError(t, err)
+	golden := "testdata/forecast.golden.json"
+	if *update {
+		require.NoError(t, ioutil.WriteFile(golden, got, 0644))
+	}
+	want, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+
+	require.Equal(t, 3, len(ns))
+
+	// Analysis header.
+	analysis := ns[0]
+	assert.Equal(t, time.Date(2020, time.June, 19, 18, 0, 0, 0, time.UTC), analysis.Time)
+	assert.Equal(t, "GFS", analysis.Model)
+	require.NotNil(t, analysis.Run)
+	assert.Equal(t, analysis.Time, *analysis.Run)
+	assert.False(t, analysis.Synthetic)
+
+	// Indices line, with missing helicity.
+	assertValue(t, 345, analysis.CAPE)
+	assertValue(t, -12, analysis.CIN)
+	assert.Nil(t, analysis.Helic)
+	assertValue(t, 24, analysis.PW)
+
+	// Only the surface and mandatory levels are in the profile.
+	assert.Equal(t, []float32{1003, 1000, 925, 850, 700, 500}, analysis.Pressure)
+	require.NotNil(t, analysis.Surface)
+	assertValue(t, 1003, analysis.Surface.Pressure)
+	assertValue(t, 28.4, analysis.Surface.Temp)
+	require.NotNil(t, analysis.Tropopause)
+	assertValue(t, 118, analysis.Tropopause.Pressure)
+	assert.Nil(t, analysis.Tropopause.WindDir)
+	require.NotNil(t, analysis.MaxWind)
+	assertValue(t, 250, analysis.MaxWind.Pressure)
+	require.NotNil(t, analysis.MaxWind.WindSpeed)
+	assert.Equal(t, 48, *analysis.MaxWind.WindSpeed)
+
+	// Missing wind of the 500 hPa level.
+	assert.Nil(t, analysis.WindDir[5])
+	assert.Nil(t, analysis.WindSpeed[5])
+	assertValue(t, -9.9, analysis.Temp[5])
+
+	// Forecast header.
+	forecast := ns[2]
+	assert.Equal(t, time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC), forecast.Time)
+	require.NotNil(t, forecast.Run)
+	assert.Equal(t, time.Date(2020, time.June, 19, 18, 0, 0, 0, time.UTC), *forecast.Run)
+	assert.Nil(t, forecast.Tropopause)
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob("testdata/invalid/*.txt")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		_, err = parse(bytes.NewReader(content))
+		assert.Error(t, err, path)
+	}
+}
+
+func TestInterpolateMissingHours(t *testing.T) {
+	t.Parallel()
+
+	content, err := ioutil.ReadFile("testdata/forecast.txt")
+	require.NoError(t, err)
+	ns, err := parse(bytes.NewReader(content))
+	require.NoError(t, err)
+
+	got, err := interpolateMissingHours(ns)
+	require.NoError(t, err)
+	require.Equal(t, 7, len(got))
+
+	start := time.Date(2020, time.June, 19, 18, 0, 0, 0, time.UTC)
+	for i, n := range got {
+		assert.Equal(t, start.Add(time.Duration(i)*time.Hour), n.Time, "got[%d].Time", i)
+		assert.Equal(t, i%3 != 0, n.Synthetic, "got[%d].Synthetic", i)
+	}
+
+	// The levels of the earlier forecast that are out of the levels of the later forecast are
+	// dropped.
+	assert.Equal(t, []float32{1003, 1000, 925, 850, 700}, got[1].Pressure)
+
+	// Interpolation across midnight, 2/3 of the way from 21Z to 00Z.
+	n := got[5]
+	assert.Equal(t, time.Date(2020, time.June, 19, 23, 0, 0, 0, time.UTC), n.Time)
+	require.Equal(t, float32(1000), n.Pressure[1])
+	assertValue(t, 22.6, n.Temp[1])
+	assertValue(t, 18.0, n.Dew[1])
+	require.NotNil(t, n.Run, "forecasts of the same run")
+	assert.Equal(t, start, *n.Run)
+
+	// Wind direction is interpolated around north: 0 deg at 14 kt and 20 deg at 12 kt.
+	require.Equal(t, float32(850), n.Pressure[3])
+	require.NotNil(t, n.WindDir[3])
+	assert.Equal(t, 13, *n.WindDir[3])
+	assert.Equal(t, 12, *n.WindSpeed[3])
+}
+
+func TestLiveGet(t *testing.T) {
+	if !*live {
+		t.Skip("The live NOAA forecast is tested with -live")
+	}
+
+	client := &recordingDoer{}
+	start := time.Now().UTC().Truncate(24 * time.Hour)
+	ns, err := Get(context.Background(), client, "GFS", "shortest", start, start.Add(12*time.Hour), 32.6, 35.23)
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, ioutil.WriteFile("testdata/forecast-live.txt", client.body, 0644))
+	}
+
+	require.NotEmpty(t, ns)
+	assert.False(t, ns[0].Time.Before(start), "first forecast at %s", ns[0].Time)
+	for i, n := range ns {
+		assert.Equal(t, "GFS", n.Model, "ns[%d].Model", i)
+		assert.Equal(t, ns[0].Time.Add(time.Duration(i)*time.Hour), n.Time, "ns[%d].Time", i)
+		assert.NotEmpty(t, n.Pressure, "ns[%d].Pressure", i)
+		assert.Equal(t, len(n.Pressure), len(n.Temp), "ns[%d].Temp", i)
+		assert.Equal(t, len(n.Pressure), len(n.WindSpeed), "ns[%d].WindSpeed", i)
+	}
+}
+
+// recordingDoer performs requests with the default client, and records the last response body.
+type recordingDoer struct {
+	body []byte
+}
+
+func (d *recordingDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	d.body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(d.body))
+	return resp, nil
+}
+
+func TestInterpolateWind(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2020, time.June, 19, 0, 0, 0, 0, time.UTC)
+	last := level(t0, 350, 10)
+	next := level(t0.Add(2*time.Hour), 10, 10)
+
+	got, err := interpolateMissingHours([]*NOAA{last, next})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(got))
+	assert.Equal(t, 0, *got[1].WindDir[0])
+	assert.Equal(t, 10, *got[1].WindSpeed[0])
+}
+
+func TestInterpolateMissingHoursErrors(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2020, time.June, 19, 0, 0, 0, 0, time.UTC)
+
+	// Columns of different lengths.
+	bad := level(t0.Add(3*time.Hour), 0, 0)
+	bad.Dew = nil
+	_, err := interpolateMissingHours([]*NOAA{level(t0, 0, 0), bad})
+	assert.Error(t, err)
+
+	// Forecasts that are not sorted.
+	_, err = interpolateMissingHours([]*NOAA{level(t0, 0, 0), level(t0, 0, 0)})
+	assert.Error(t, err)
+}
+
+// level returns a forecast with a single level with the given wind.
+func level(t time.Time, windDir, windSpeed int) *NOAA {
+	v := float32(10)
+	return &NOAA{
+		Time:      t,
+		Pressure:  []float32{1000},
+		Height:    []float32{100},
+		Temp:      []*float32{&v},
+		Dew:       []*float32{&v},
+		WindDir:   []*int{&windDir},
+		WindSpeed: []*int{&windSpeed},
+	}
+}
+
+func assertValue(t *testing.T, want float32, got *float32) {
+	t.Helper()
+	if assert.NotNil(t, got) {
+		assert.InDelta(t, want, *got, 0.01)
+	}
+}
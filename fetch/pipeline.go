@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// now returns the time in which records were fetched. Tests replace it to get reproducible
+// output.
+var now = time.Now
+
+// result of fetching a source for a single location.
+type result struct {
+	records []Record
+	err     error
+	// fetched is the time in which the fetch completed.
+	fetched time.Time
+}
+
+// fetchAll fetches all the given sources for all the locations concurrently. Each source is
+// fetched with at most the number of workers configured for it. The returned results are
+// indexed by the source and then by the location, in the order of the arguments.
+func fetchAll(ctx context.Context, srcs []Source, locs []Location, start, end time.Time, workers map[string]int) [][]result {
+	results := make([][]result, len(srcs))
+	var wg sync.WaitGroup
+	for i, src := range srcs {
+		results[i] = make([]result, len(locs))
+		sem := make(chan struct{}, workersOf(workers, src.Name()))
+		for j, loc := range locs {
+			wg.Add(1)
+			go func(src Source, loc Location, r *result) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				r.records, r.err = src.Fetch(ctx, loc, start, end)
+				r.fetched = now()
+				if r.err == nil {
+					log.Printf("Fetched %d %s records for %s", len(r.records), src.Name(), loc.Name)
+				}
+			}(src, loc, &results[i][j])
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// Fetch statuses of a source.
+const (
+	statusOK      = "ok"
+	statusPartial = "partial"
+	statusFailed  = "failed"
+	// statusSkipped is the status of a source that is not configured. It is not returned by run,
+	// so it does not fail the run.
+	statusSkipped = "skipped"
+)
+
+// run fetches the given sources for all the locations and adds the fetched data to the store.
+// Fetched data is merged in the order of the sources and locations, so that the output does not
+// depend on the order in which fetches completed. A failed fetch does not stop the run, it is
+// recorded in the index of the source. If clip is true, records outside of the time window are
+// dropped. Sources that are not configured are skipped. It returns the fetch status of each of
+// the sources that were fetched.
+func run(ctx context.Context, st *store, srcs []Source, locs []Location, start, end time.Time, clip bool, workers map[string]int) map[string]string {
+	statuses := map[string]string{}
+	srcs = configuredSources(srcs)
+	results := fetchAll(ctx, srcs, locs, start, end, workers)
+	for i, src := range srcs {
+		idx := index.source(src.Name())
+		failed := 0
+		for j, loc := range locs {
+			r := results[i][j]
+			if r.err != nil {
+				log.Printf("Fetching %s for %s failed: %s", src.Name(), loc.Name, r.err)
+				failed++
+				idx.LastError = fmt.Sprintf("%s: %s", loc.Name, r.err)
+				idx.LastErrorTime = time.Now().In(timezone)
+				continue
+			}
+			records := r.records
+			if clip {
+				records = clipRecords(records, start, end)
+			}
+			for _, rec := range records {
+				st.add(rec, src, r.fetched)
+			}
+			src.UpdateIndex(idx, records)
+		}
+		if es, ok := src.(extraSource); ok {
+			runExtra(ctx, st, src, es, idx, start, end, clip)
+		}
+		switch {
+		case failed == 0:
+			idx.Status = statusOK
+		case failed < len(locs):
+			idx.Status = statusPartial
+		default:
+			idx.Status = statusFailed
+		}
+		statuses[src.Name()] = idx.Status
+	}
+	return statuses
+}
+
+// configuredSources returns the sources that are configured, and records the status of the
+// skipped ones in their index.
+func configuredSources(srcs []Source) []Source {
+	var configured []Source
+	for _, src := range srcs {
+		if c, ok := src.(configurer); ok {
+			if err := c.configured(); err != nil {
+				log.Printf("Skipping %s, it is not configured: %s", src.Name(), err)
+				idx := index.source(src.Name())
+				idx.Status = statusSkipped
+				idx.LastError = fmt.Sprintf("not configured: %s", err)
+				idx.LastErrorTime = time.Now().In(timezone)
+				continue
+			}
+		}
+		configured = append(configured, src)
+	}
+	return configured
+}
+
+// runExtra fetches the extra locations of a source and adds their data to the store. A failure
+// is recorded in the index of the source, but does not affect its status.
+func runExtra(ctx context.Context, st *store, src Source, es extraSource, idx *SourceIndex, start, end time.Time, clip bool) {
+	extra, records, err := es.FetchExtra(ctx, start, end)
+	if err != nil {
+		log.Printf("Fetching %s extra locations failed: %s", src.Name(), err)
+		idx.LastError = fmt.Sprintf("extra locations: %s", err)
+		idx.LastErrorTime = time.Now().In(timezone)
+		return
+	}
+	if len(extra) == 0 {
+		return
+	}
+	if clip {
+		records = clipRecords(records, start, end)
+	}
+	fetched := now()
+	for _, rec := range records {
+		st.add(rec, src, fetched)
+	}
+	src.UpdateIndex(idx, records)
+	if index.ExtraLocations == nil {
+		index.ExtraLocations = map[location]ExtraLocation{}
+	}
+	for key, l := range extra {
+		index.ExtraLocations[key] = l
+	}
+	log.Printf("Fetched %d %s records for %d extra locations", len(records), src.Name(), len(extra))
+}
+
+// clipRecords returns the records within the time window [start, end).
+func clipRecords(records []Record, start, end time.Time) []Record {
+	var clipped []Record
+	for _, r := range records {
+		if !r.Time.Before(start) && r.Time.Before(end) {
+			clipped = append(clipped, r)
+		}
+	}
+	return clipped
+}
+
+// Policies for failing the run according to the fetch statuses of the sources.
+const (
+	failNever  = "never"
+	failAny    = "any"
+	failSource = "source"
+	failAll    = "all"
+)
+
+func validFailPolicy(policy string) bool {
+	switch policy {
+	case failNever, failAny, failSource, failAll:
+		return true
+	}
+	return false
+}
+
+// shouldFail returns true if the run should fail according to the given policy:
+//
+//	never:  never fail.
+//	any:    fail if any fetch failed.
+//	source: fail if any source failed for all the locations.
+//	all:    fail if all the sources failed for all the locations.
+func shouldFail(policy string, statuses map[string]string) bool {
+	ok, failed := 0, 0
+	for _, status := range statuses {
+		switch status {
+		case statusOK:
+			ok++
+		case statusFailed:
+			failed++
+		}
+	}
+	switch policy {
+	case failAny:
+		return ok < len(statuses)
+	case failSource:
+		return failed > 0
+	case failAll:
+		return len(statuses) > 0 && failed == len(statuses)
+	default:
+		return false
+	}
+}
+
+// parseWorkers parses a comma separated list of source=workers pairs.
+func parseWorkers(s string) (map[string]int, error) {
+	workers := map[string]int{}
+	if strings.TrimSpace(s) == "" {
+		return workers, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid workers %q, expected source=workers", pair)
+		}
+		name := strings.TrimSpace(kv[0])
+		if lookupSource(name) == nil {
+			return nil, fmt.Errorf("invalid workers %q: unknown source %q", pair, name)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid workers %q: expected a positive number", pair)
+		}
+		workers[name] = n
+	}
+	return workers, nil
+}
+
+// workersOf returns the number of workers of a source. Sources without configuration are
+// fetched by a single worker.
+func workersOf(workers map[string]int, name string) int {
+	if n := workers[name]; n > 0 {
+		return n
+	}
+	return 1
+}
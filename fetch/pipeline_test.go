@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource stores the records of all the locations under the same key, so the stored data
+// depends on the order in which the records are merged. Fetches complete after the delay of the
+// location.
+type fakeSource struct {
+	name  string
+	delay map[string]time.Duration
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) Key(Location) location { return "shared" }
+
+func (s *fakeSource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	time.Sleep(s.delay[loc.Name])
+	var records []Record
+	for t := start; t.Before(end); t = t.Add(6 * time.Hour) {
+		records = append(records, Record{
+			Time:     t,
+			Location: s.Key(loc),
+			Data:     map[string]string{"location": loc.Name, "source": s.name},
+		})
+	}
+	return records, nil
+}
+
+func (s *fakeSource) UpdateIndex(idx *SourceIndex, records []Record) {}
+
+func TestRunDeterministic(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return time.Date(2020, time.June, 20, 12, 0, 0, 0, time.UTC) }
+
+	var locs []Location
+	for i := 0; i < 8; i++ {
+		locs = append(locs, Location{Name: fmt.Sprintf("loc-%d", i)})
+	}
+	start := time.Date(2020, time.June, 20, 0, 0, 0, 0, timezone)
+	end := start.AddDate(0, 0, 2)
+
+	// runOnce runs the fake sources with random fetch completion order in a new directory, and
+	// returns the content of the flushed files by their path.
+	runOnce := func() map[string]string {
+		var srcs []Source
+		for _, name := range []string{"a", "b"} {
+			delay := map[string]time.Duration{}
+			for i, j := range rand.Perm(len(locs)) {
+				delay[locs[i].Name] = time.Duration(j) * time.Millisecond
+			}
+			srcs = append(srcs, &fakeSource{name: name, delay: delay})
+		}
+
+		chdir(t, t.TempDir())
+		st := newStore()
+		statuses := run(context.Background(), st, srcs, locs, start, end, false, map[string]int{"a": 8, "b": 8})
+		assert.Equal(t, map[string]string{"a": statusOK, "b": statusOK}, statuses)
+		files := map[string]string{}
+		for _, path := range st.flush() {
+			content, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			files[path] = string(content)
+		}
+		return files
+	}
+
+	want := runOnce()
+	require.Equal(t, 2, len(want))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, want, runOnce())
+	}
+
+	// The records are merged in the order of the locations, so the last location wins.
+	var day dayData
+	require.NoError(t, json.Unmarshal([]byte(want[outputPath(start)]), &day))
+	var data map[string]string
+	require.True(t, day[0]["shared"].decode("a", &data))
+	assert.Equal(t, "loc-7", data["location"])
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestShouldFail(t *testing.T) {
+	t.Parallel()
+
+	var (
+		allOK   = map[string]string{"noaa": statusOK, "ims": statusOK}
+		partial = map[string]string{"noaa": statusOK, "ims": statusPartial}
+		oneFail = map[string]string{"noaa": statusOK, "ims": statusFailed}
+		allFail = map[string]string{"noaa": statusFailed, "ims": statusFailed}
+		empty   = map[string]string{}
+	)
+
+	tests := []struct {
+		policy   string
+		statuses map[string]string
+		want     bool
+	}{
+		{policy: failNever, statuses: allOK, want: false},
+		{policy: failNever, statuses: allFail, want: false},
+		{policy: failNever, statuses: empty, want: false},
+
+		{policy: failAny, statuses: allOK, want: false},
+		{policy: failAny, statuses: partial, want: true},
+		{policy: failAny, statuses: oneFail, want: true},
+		{policy: failAny, statuses: empty, want: false},
+
+		{policy: failSource, statuses: allOK, want: false},
+		{policy: failSource, statuses: partial, want: false},
+		{policy: failSource, statuses: oneFail, want: true},
+		{policy: failSource, statuses: empty, want: false},
+
+		{policy: failAll, statuses: partial, want: false},
+		{policy: failAll, statuses: oneFail, want: false},
+		{policy: failAll, statuses: allFail, want: true},
+		{policy: failAll, statuses: empty, want: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, shouldFail(tt.policy, tt.statuses), "policy %s, statuses %v", tt.policy, tt.statuses)
+	}
+}
+
+func TestParseWorkers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{value: "", want: map[string]int{}},
+		{value: " ", want: map[string]int{}},
+		{value: "noaa=2", want: map[string]int{"noaa": 2}},
+		{value: "noaa=2, uwyo = 3", want: map[string]int{"noaa": 2, "uwyo": 3}},
+		{value: "noaa", wantErr: true},
+		{value: "noaa=", wantErr: true},
+		{value: "noaa=0", wantErr: true},
+		{value: "noaa=-1", wantErr: true},
+		{value: "noaa=two", wantErr: true},
+		{value: "unknown=2", wantErr: true},
+		{value: "noaa=2,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWorkers(tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, "value %q", tt.value)
+			continue
+		}
+		if assert.NoError(t, err, "value %q", tt.value) {
+			assert.Equal(t, tt.want, got, "value %q", tt.value)
+		}
+	}
+}
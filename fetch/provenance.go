@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// provenanceKey is the key in the day files of the provenance of the data of each of the
+// sources of a location.
+const provenanceKey = "provenance"
+
+// Provenance describes the origin of the data of a source.
+type Provenance struct {
+	// FetchTime is the time in which the data was fetched.
+	FetchTime time.Time
+	// URL from which the data was fetched, without secrets.
+	URL string `json:",omitempty"`
+	// Model and Run are the model and the initialization time of the model run of forecasts.
+	Model string     `json:",omitempty"`
+	Run   *time.Time `json:",omitempty"`
+	// Synthetic is true for data that was interpolated rather than fetched.
+	Synthetic bool `json:",omitempty"`
+	// Version of the fetcher: the last git commit that changed its code, or "dev".
+	Version string
+	// Hash of the stored data, that changes when the data changes.
+	Hash string
+}
+
+// version of the fetcher.
+var version = fetcherVersion()
+
+// fetcherVersion returns the last commit that changed the fetcher code. The commit that triggered
+// the run is not used, as it is usually a data commit of the previous run. The paths are relative
+// to the top of the repository, so that the version does not depend on the working directory.
+func fetcherVersion() string {
+	out, err := exec.Command("git", "log", "-1", "--format=%H", "--", ":(top)fetch", ":(top)go.mod", ":(top)go.sum").Output()
+	if err != nil {
+		log.Printf("Getting fetcher version: %s", err)
+		return "dev"
+	}
+	if sha := strings.TrimSpace(string(out)); sha != "" {
+		return sha
+	}
+	return "dev"
+}
+
+// setProvenance sets the provenance of the data of the given source.
+func (s sources) setProvenance(name string, p Provenance) {
+	provs := map[string]Provenance{}
+	if raw, ok := s[provenanceKey]; ok {
+		err := json.Unmarshal(raw, &provs)
+		if err != nil {
+			log.Printf("Decode provenance: %v", err)
+			provs = map[string]Provenance{}
+		}
+	}
+	provs[name] = p
+	s.set(provenanceKey, provs)
+}
+
+// contentHash returns a short hash of stored data.
+func contentHash(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// secretParams are substrings of URL query parameter names that hold secrets.
+var secretParams = []string{"token", "key", "secret", "password", "auth"}
+
+// redactURL returns the URL with the values of secret query parameters and the user password
+// removed.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(parsed.User.Username())
+	}
+	q := parsed.Query()
+	redacted := false
+	for name := range q {
+		for _, secret := range secretParams {
+			if strings.Contains(strings.ToLower(name), secret) {
+				q.Set(name, "REDACTED")
+				redacted = true
+				break
+			}
+		}
+	}
+	if redacted {
+		parsed.RawQuery = q.Encode()
+	}
+	return parsed.String()
+}
@@ -0,0 +1,137 @@
+// Package retry provides an HTTP client that retries failed requests with exponential backoff.
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Doer performs HTTP requests. It is implemented by *http.Client and by *Client.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client is an HTTP client that retries requests that failed with a server error, with a
+// "too many requests" status or with a timeout. The delay between attempts grows exponentially,
+// unless the server asks for a specific delay with the Retry-After header. A request whose
+// Retry-After delay is longer than MaxRetryAfter is not retried.
+//
+// Requests are retried as is, so they must not have a body.
+type Client struct {
+	// HTTP performs the requests. If nil, http.DefaultClient is used.
+	HTTP Doer
+	// Attempts is the maximum number of attempts of each request.
+	Attempts int
+	// MinBackoff is the delay before the first retry.
+	MinBackoff time.Duration
+	// MaxBackoff limits the exponential delay between attempts.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest Retry-After delay that is waited for. Zero means no limit.
+	MaxRetryAfter time.Duration
+}
+
+// New returns a client with the given timeout for each attempt and the given number of
+// attempts.
+func New(timeout time.Duration, attempts int) *Client {
+	return &Client{
+		HTTP:          &http.Client{Timeout: timeout},
+		Attempts:      attempts,
+		MinBackoff:    time.Second,
+		MaxBackoff:    time.Minute,
+		MaxRetryAfter: 5 * time.Minute,
+	}
+}
+
+// Do performs the request, retrying it on failure. The request context cancels the request
+// and the waiting between attempts.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := c.MinBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := c.http().Do(req)
+		reason := retryReason(resp, err)
+		if reason == "" || attempt >= c.Attempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff
+		if c.MaxBackoff > 0 && wait > c.MaxBackoff {
+			wait = c.MaxBackoff
+		}
+		if d, ok := retryAfter(resp); ok {
+			if c.MaxRetryAfter > 0 && d > c.MaxRetryAfter {
+				// Retrying earlier than the server asked is likely to fail again.
+				log.Printf("Not retrying %s after attempt %d: %s, Retry-After %s is longer than %s", req.URL.Host, attempt, reason, d, c.MaxRetryAfter)
+				return resp, err
+			}
+			wait = d
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		log.Printf("Retrying %s in %s after attempt %d: %s", req.URL.Host, wait, attempt, reason)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) http() Doer {
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+	return c.HTTP
+}
+
+// retryReason returns the reason to retry a request, or an empty string if it should not be
+// retried.
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return err.Error()
+		}
+		return ""
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("bad status: %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the response. The header
+// value is either a number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+// Package runway computes wind components relative to a runway.
+package runway
+
+import (
+	"fmt"
+	"math"
+)
+
+// Components of the wind relative to a runway direction.
+type Components struct {
+	// Headwind in knots. Negative for tailwind.
+	Headwind float32
+	// Crosswind in knots. Positive for wind from the right.
+	Crosswind float32
+}
+
+// Report is the wind report of a runway, in both of its directions.
+type Report struct {
+	// Directions holds the wind components of each of the runway directions, keyed by the
+	// runway designator (for example "27" and "09").
+	Directions map[string]Components
+	// MaxCrosswind is the crosswind limit in knots.
+	MaxCrosswind float32
+	// ExceedsCrosswind is true if the crosswind exceeds the limit.
+	ExceedsCrosswind bool
+}
+
+// Compute returns the report of a runway with the given direction (in degrees) for a wind
+// from windDir (in degrees) with windSpeed (in knots).
+func Compute(runwayDir int, windDir, windSpeed, maxCrosswind float64) Report {
+	c := Wind(float64(runwayDir), windDir, windSpeed)
+	reciprocal := (runwayDir + 180) % 360
+	return Report{
+		Directions: map[string]Components{
+			Designator(runwayDir):  c,
+			Designator(reciprocal): {Headwind: -c.Headwind, Crosswind: -c.Crosswind},
+		},
+		MaxCrosswind:     float32(maxCrosswind),
+		ExceedsCrosswind: math.Abs(float64(c.Crosswind)) > maxCrosswind,
+	}
+}
+
+// Wind returns the wind components relative to a runway direction. All directions are in
+// degrees.
+func Wind(runwayDir, windDir, windSpeed float64) Components {
+	angle := (windDir - runwayDir) * math.Pi / 180
+	return Components{
+		Headwind:  float32(windSpeed * math.Cos(angle)),
+		Crosswind: float32(windSpeed * math.Sin(angle)),
+	}
+}
+
+// Designator returns the runway designator of a runway direction in degrees.
+func Designator(dir int) string {
+	n := int(math.Round(float64(dir)/10)) % 36
+	if n == 0 {
+		n = 36
+	}
+	return fmt.Sprintf("%02d", n)
+}
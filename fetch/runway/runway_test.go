@@ -0,0 +1,46 @@
+package runway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	t.Parallel()
+
+	// Wind from 300 at 20 knots on runway 27.
+	r := Compute(270, 300, 20, 8)
+
+	assert.InDelta(t, 17.32, r.Directions["27"].Headwind, 0.01)
+	assert.InDelta(t, 10, r.Directions["27"].Crosswind, 0.01)
+	assert.InDelta(t, -17.32, r.Directions["09"].Headwind, 0.01)
+	assert.InDelta(t, -10, r.Directions["09"].Crosswind, 0.01)
+	assert.Equal(t, float32(8), r.MaxCrosswind)
+	assert.True(t, r.ExceedsCrosswind)
+
+	// Wind along the runway.
+	r = Compute(270, 90, 15, 8)
+	assert.InDelta(t, -15, r.Directions["27"].Headwind, 0.01)
+	assert.InDelta(t, 0, r.Directions["27"].Crosswind, 0.01)
+	assert.False(t, r.ExceedsCrosswind)
+}
+
+func TestDesignator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dir  int
+		want string
+	}{
+		{dir: 270, want: "27"},
+		{dir: 90, want: "09"},
+		{dir: 0, want: "36"},
+		{dir: 356, want: "36"},
+		{dir: 184, want: "18"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Designator(tt.dir), "direction %d", tt.dir)
+	}
+}
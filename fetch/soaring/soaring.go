@@ -0,0 +1,181 @@
+// Package soaring computes soaring products from an atmospheric profile and a surface
+// temperature forecast.
+package soaring
+
+import (
+	"math"
+
+	"github.com/airsounds/data/fetch/noaa"
+	"github.com/airsounds/data/fetch/uwyo"
+)
+
+const (
+	// dryLapseRate is the dry adiabatic lapse rate in deg C per foot (9.8 deg C per km).
+	dryLapseRate = 9.8 / 3280.84
+	// cloudBaseRate is the height in feet of the cumulus cloud base above the ground for each
+	// deg C of spread between the surface temperature and dew point.
+	cloudBaseRate = 410
+	// triggerHeight is the height in feet above the ground that usable lift must reach for
+	// the trigger temperature.
+	triggerHeight = 3000
+	// usableLift is the thermal index up to which lift is considered usable.
+	usableLift = -2
+)
+
+// Profile is an atmospheric profile. Levels are ordered by ascending height.
+type Profile struct {
+	// Height in feet
+	Height []float64
+	// Temp in deg C
+	Temp []float64
+	// Dew point in deg C
+	Dew []float64
+}
+
+// FromNOAA returns the profile of a NOAA forecast.
+func FromNOAA(n *noaa.NOAA) Profile {
+	var p Profile
+	for i := range n.Height {
+		// Levels with missing values are skipped.
+		if i >= len(n.Temp) || i >= len(n.Dew) || n.Temp[i] == nil || n.Dew[i] == nil {
+			continue
+		}
+		p.Height = append(p.Height, float64(n.Height[i]))
+		p.Temp = append(p.Temp, float64(*n.Temp[i]))
+		p.Dew = append(p.Dew, float64(*n.Dew[i]))
+	}
+	return p
+}
+
+// FromUWYO returns the profile of a UWYO sounding.
+func FromUWYO(u *uwyo.UWYO) Profile {
+	var p Profile
+	for i := range u.Height {
+		// Levels with missing values are skipped.
+		if i >= len(u.Temp) || i >= len(u.Dew) || u.Height[i] == nil || u.Temp[i] == nil || u.Dew[i] == nil {
+			continue
+		}
+		p.Height = append(p.Height, float64(*u.Height[i]))
+		p.Temp = append(p.Temp, float64(*u.Temp[i]))
+		p.Dew = append(p.Dew, float64(*u.Dew[i]))
+	}
+	return p
+}
+
+// Derived are the soaring products of a profile at a location.
+type Derived struct {
+	// Height in feet of the profile levels that are above the location.
+	Height []int
+	// ThermalIndex in deg C at each of the levels in Height.
+	ThermalIndex []float32
+	// TopOfLift is the height in feet where the thermal index reaches -2. It is the top of the
+	// usable lift. Nil if the thermal index does not reach -2 within the profile.
+	TopOfLift *float32 `json:",omitempty"`
+	// ThermalTop is the height in feet where the thermal index reaches 0. Nil if the thermal
+	// index does not reach 0 within the profile.
+	ThermalTop *float32 `json:",omitempty"`
+	// TriggerTemp is the surface temperature in deg C for which the usable lift reaches
+	// 3000 feet above the ground.
+	TriggerTemp *float32 `json:",omitempty"`
+	// CloudBase is the height in feet of the cumulus cloud base.
+	CloudBase *float32 `json:",omitempty"`
+}
+
+// Compute computes the soaring products of a profile for a location at the altitude alt (in
+// feet) with the given surface temperature. The surface dew point is used to compute the cloud
+// base, and if it is nil, the dew point of the profile at the ground level is used instead.
+func Compute(p Profile, alt float64, surfaceTemp float64, surfaceDew *float64) Derived {
+	var d Derived
+
+	// Thermal index at the ground level.
+	groundTemp, ok := p.at(p.Temp, alt)
+	if !ok {
+		return d
+	}
+	lastHeight, lastTI := alt, groundTemp-surfaceTemp
+	if lastTI >= usableLift {
+		d.TopOfLift = float32p(alt)
+	}
+	if lastTI >= 0 {
+		d.ThermalTop = float32p(alt)
+	}
+
+	for i, h := range p.Height {
+		if h <= alt {
+			continue
+		}
+		ti := thermalIndex(p.Temp[i], surfaceTemp, h-alt)
+		d.Height = append(d.Height, int(h))
+		d.ThermalIndex = append(d.ThermalIndex, float32(ti))
+		if d.TopOfLift == nil && ti >= usableLift {
+			d.TopOfLift = float32p(crossing(lastHeight, lastTI, h, ti, usableLift))
+		}
+		if d.ThermalTop == nil && ti >= 0 {
+			d.ThermalTop = float32p(crossing(lastHeight, lastTI, h, ti, 0))
+		}
+		lastHeight, lastTI = h, ti
+	}
+
+	if t, ok := p.at(p.Temp, alt+triggerHeight); ok {
+		d.TriggerTemp = float32p(t - usableLift + dryLapseRate*triggerHeight)
+	}
+
+	dew, ok := p.at(p.Dew, alt)
+	if surfaceDew != nil {
+		dew, ok = *surfaceDew, true
+	}
+	if ok {
+		d.CloudBase = float32p(alt + cloudBaseRate*(surfaceTemp-dew))
+	}
+	return d
+}
+
+// DewPoint returns the dew point in deg C for the given temperature in deg C and relative
+// humidity in percent, using the Magnus formula.
+func DewPoint(temp, relHum float64) float64 {
+	const b, c = 17.62, 243.12
+	gamma := math.Log(relHum/100) + b*temp/(c+temp)
+	return c * gamma / (b - gamma)
+}
+
+// thermalIndex is the difference between the environment temperature and the temperature of a
+// parcel that rose dry adiabatically from the surface to the given height above the ground.
+func thermalIndex(envTemp, surfaceTemp, height float64) float64 {
+	return envTemp - (surfaceTemp - dryLapseRate*height)
+}
+
+// at returns the value of a profile field at the given height, linearly interpolated between
+// the levels. Below the lowest level, the value of the lowest level is used.
+func (p Profile) at(values []float64, h float64) (float64, bool) {
+	if len(p.Height) == 0 {
+		return 0, false
+	}
+	if h <= p.Height[0] {
+		return values[0], true
+	}
+	for i := 1; i < len(p.Height); i++ {
+		if p.Height[i] < h {
+			continue
+		}
+		h0, h1 := p.Height[i-1], p.Height[i]
+		if h1 == h0 {
+			return values[i], true
+		}
+		return values[i-1] + (h-h0)/(h1-h0)*(values[i]-values[i-1]), true
+	}
+	return 0, false
+}
+
+// crossing returns the height between h0 and h1 in which the thermal index crosses the given
+// value.
+func crossing(h0, ti0, h1, ti1, value float64) float64 {
+	if ti1 == ti0 {
+		return h1
+	}
+	return h0 + (value-ti0)/(ti1-ti0)*(h1-h0)
+}
+
+func float32p(v float64) *float32 {
+	f := float32(v)
+	return &f
+}
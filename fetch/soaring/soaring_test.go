@@ -0,0 +1,64 @@
+package soaring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	t.Parallel()
+
+	p := Profile{
+		Height: []float64{0, 1000, 2000, 3000, 4000, 5000},
+		Temp:   []float64{20, 18, 16, 15, 14, 12},
+		Dew:    []float64{10, 9, 8, 7, 6, 5},
+	}
+	dew := 15.0
+	d := Compute(p, 0, 25, &dew)
+
+	assert.Equal(t, []int{1000, 2000, 3000, 4000, 5000}, d.Height)
+	require.Equal(t, 5, len(d.ThermalIndex))
+	assert.InDelta(t, -4.01, d.ThermalIndex[0], 0.01)
+	assert.InDelta(t, 0.95, d.ThermalIndex[3], 0.01)
+
+	require.NotNil(t, d.TopOfLift)
+	assert.InDelta(t, 2516, *d.TopOfLift, 1)
+	require.NotNil(t, d.ThermalTop)
+	assert.InDelta(t, 3523, *d.ThermalTop, 1)
+	require.NotNil(t, d.TriggerTemp)
+	assert.InDelta(t, 25.96, *d.TriggerTemp, 0.01)
+	require.NotNil(t, d.CloudBase)
+	assert.InDelta(t, 4100, *d.CloudBase, 1)
+
+	// Without surface dew point, the profile dew point at the ground is used.
+	d = Compute(p, 0, 25, nil)
+	require.NotNil(t, d.CloudBase)
+	assert.InDelta(t, 6150, *d.CloudBase, 1)
+}
+
+func TestComputeNoLift(t *testing.T) {
+	t.Parallel()
+
+	// Surface is colder than the profile, there is no lift at all.
+	p := Profile{
+		Height: []float64{500, 1500},
+		Temp:   []float64{20, 19},
+		Dew:    []float64{10, 9},
+	}
+	d := Compute(p, 1000, 18, nil)
+	require.NotNil(t, d.TopOfLift)
+	assert.Equal(t, float32(1000), *d.TopOfLift)
+	require.NotNil(t, d.ThermalTop)
+	assert.Equal(t, float32(1000), *d.ThermalTop)
+	// Trigger height is above the profile.
+	assert.Nil(t, d.TriggerTemp)
+}
+
+func TestDewPoint(t *testing.T) {
+	t.Parallel()
+
+	assert.InDelta(t, 9.26, DewPoint(20, 50), 0.01)
+	assert.InDelta(t, 20, DewPoint(20, 100), 0.01)
+}
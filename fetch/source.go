@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Source is a provider of forecast or measurement data.
+//
+// A new provider is added by implementing this interface and registering it with register in
+// an init function.
+type Source interface {
+	// Name of the source. It is used as a value of the -source flag, as the key of the data in
+	// the day files and as the key of the source in the index.
+	Name() string
+	// Fetch returns the records of the given location within the given time window. It may be
+	// called concurrently for different locations.
+	Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error)
+	// UpdateIndex updates the index of the source with the fetched records.
+	UpdateIndex(idx *SourceIndex, records []Record)
+}
+
+// Record is a single timed data item returned by a source.
+type Record struct {
+	Time time.Time
+	// Location is the key under which the record is stored in the day file.
+	Location location
+	Data     interface{}
+	// Provenance of the record. Sources set the fields that describe the origin of the data, and
+	// the rest are set when the record is stored.
+	Provenance Provenance
+}
+
+// keyer is implemented by sources that store the records of a location under a key other than
+// the location name.
+type keyer interface {
+	Key(loc Location) location
+}
+
+// storageKey returns the key under which a source stores the records of a location.
+func storageKey(s Source, loc Location) location {
+	if k, ok := s.(keyer); ok {
+		return k.Key(loc)
+	}
+	return location(loc.Name)
+}
+
+// forecaster is implemented by sources of forecasts, whose data for a given time changes between
+// issuances. The issuances of forecasts are kept in the forecast history mode.
+type forecaster interface {
+	isForecast()
+}
+
+// backfiller is implemented by sources that can fetch data of past time windows, and can
+// therefore be backfilled.
+type backfiller interface {
+	canBackfill()
+}
+
+// configurer is implemented by sources that need configuration. A source that is not configured
+// is skipped.
+type configurer interface {
+	// configured returns an error that describes the missing configuration, if the source is
+	// not configured.
+	configured() error
+}
+
+// extraSource is implemented by sources that store data also for locations that are not in the
+// locations file. FetchExtra is called after Fetch was called for all the locations, and returns
+// the extra locations by their key, and their records.
+type extraSource interface {
+	FetchExtra(ctx context.Context, start, end time.Time) (map[location]ExtraLocation, []Record, error)
+}
+
+// ExtraLocation is a location that is not in the locations file.
+type ExtraLocation struct {
+	// Source that provides the location.
+	Source string
+	// Name of the location in the source.
+	Name string
+	Lat  float32
+	Long float32
+	// Alt of the location in feet.
+	Alt int
+}
+
+// registry holds all registered sources, in registration order.
+var registry []Source
+
+func register(s Source) {
+	if lookupSource(s.Name()) != nil {
+		panic(fmt.Sprintf("source %q registered twice", s.Name()))
+	}
+	registry = append(registry, s)
+}
+
+func lookupSource(name string) Source {
+	for _, s := range registry {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// selectSources returns the sources listed in a comma separated list of names. An empty list
+// selects all the registered sources.
+func selectSources(names string) ([]Source, error) {
+	if strings.TrimSpace(names) == "" {
+		return registry, nil
+	}
+	var srcs []Source
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		s := lookupSource(name)
+		if s == nil {
+			return nil, fmt.Errorf("unknown source %q, available sources: %s", name, strings.Join(sourceNames(registry), ", "))
+		}
+		srcs = append(srcs, s)
+	}
+	return srcs, nil
+}
+
+// sourceNames returns the names of the given sources.
+func sourceNames(srcs []Source) []string {
+	var names []string
+	for _, s := range srcs {
+		names = append(names, s.Name())
+	}
+	return names
+}
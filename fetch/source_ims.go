@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/airsounds/data/fetch/ims"
+)
+
+func init() {
+	register(&imsSource{})
+}
+
+// imsSource fetches the hourly surface forecast from IMS. A single IMS request returns the
+// forecast of all the locations, so it is fetched once and served for all locations.
+type imsSource struct {
+	once      sync.Once
+	forecasts []ims.Forecast
+	err       error
+}
+
+func (*imsSource) Name() string { return "ims" }
+
+func (*imsSource) isForecast() {}
+
+func (s *imsSource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	forecasts, err := s.predict(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, m, ok := imsForecast(loc, forecasts)
+	if !ok {
+		if loc.IMSName != "" {
+			return nil, fmt.Errorf("IMS name %q is not in the IMS forecast", loc.IMSName)
+		}
+		return nil, fmt.Errorf("no IMS forecast locations")
+	}
+	index.setMapping(loc, s.Name(), m)
+	return imsRecords(forecast, location(loc.Name)), nil
+}
+
+// FetchExtra returns the forecasts of the IMS locations that are not mapped to any location in
+// the locations file, if the -ims-all flag is set. They are stored under the key "ims-<slug>",
+// where the slug is generated from the IMS location name.
+func (s *imsSource) FetchExtra(ctx context.Context, start, end time.Time) (map[location]ExtraLocation, []Record, error) {
+	if !*imsAll {
+		return nil, nil, nil
+	}
+	forecasts, err := s.predict(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapped := map[string]bool{}
+	names := map[location]bool{}
+	for _, loc := range locations {
+		if forecast, _, ok := imsForecast(loc, forecasts); ok {
+			mapped[forecast.Name] = true
+		}
+		names[location(loc.Name)] = true
+	}
+	extra := map[location]ExtraLocation{}
+	var records []Record
+	for _, forecast := range forecasts {
+		if mapped[forecast.Name] {
+			continue
+		}
+		key := location("ims-" + slug(forecast.Name))
+		if names[key] || extra[key].Name != "" {
+			log.Printf("Skipping IMS location %q, key %s is already used", forecast.Name, key)
+			continue
+		}
+		extra[key] = ExtraLocation{
+			Source: s.Name(),
+			Name:   forecast.Name,
+			Lat:    forecast.Lat,
+			Long:   forecast.Long,
+			Alt:    int(forecast.Elevation * 3.28084), // Convert meters to feet.
+		}
+		records = append(records, imsRecords(forecast, key)...)
+	}
+	return extra, records, nil
+}
+
+// predict fetches the IMS forecast once, and returns it for all the calls.
+func (s *imsSource) predict(ctx context.Context) ([]ims.Forecast, error) {
+	s.once.Do(func() { s.forecasts, s.err = ims.Predict(ctx, httpClient) })
+	return s.forecasts, s.err
+}
+
+// imsRecords returns the records of an IMS location forecast, stored under the given key.
+func imsRecords(forecast ims.Forecast, key location) []Record {
+	var records []Record
+	for _, f := range forecast.Forecast {
+		f := f
+		records = append(records, Record{
+			Time:       f.Time.Time,
+			Location:   key,
+			Data:       &f,
+			Provenance: Provenance{URL: redactURL(forecast.URL)},
+		})
+	}
+	return records
+}
+
+// slug returns a lower case key of a name, with words separated by dashes.
+func slug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+func (*imsSource) UpdateIndex(idx *SourceIndex, records []Record) {
+	idx.LastUpdate = time.Now()
+	idx.extend(records)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/airsounds/data/fetch/ims"
+)
+
+func init() {
+	register(imsMeasureSource{})
+}
+
+// imsMeasureSource fetches surface measurements from the IMS Envista API stations.
+type imsMeasureSource struct{}
+
+func (imsMeasureSource) Name() string { return "ims-measure" }
+
+func (imsMeasureSource) canBackfill() {}
+
+func (imsMeasureSource) configured() error {
+	if imsToken == "" {
+		return fmt.Errorf("IMS_API_TOKEN is not set")
+	}
+	return nil
+}
+
+func (imsMeasureSource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	if loc.IMSStation == 0 {
+		return nil, nil
+	}
+
+	// Measurements are available only for the past.
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	var records []Record
+	for day := start.In(timezone); day.Before(end); day = day.Add(24 * time.Hour) {
+		ms, err := ims.Measure(ctx, httpClient, imsToken, ims.Station(loc.IMSStation), day)
+		if err != nil {
+			return nil, fmt.Errorf("measurements of %s: %s", day.Format("2006/01/02"), err)
+		}
+		for _, m := range ms {
+			m := m
+			records = append(records, Record{
+				Time:       m.Time.UTC(),
+				Location:   location(loc.Name),
+				Data:       &m,
+				Provenance: Provenance{URL: redactURL(m.URL)},
+			})
+		}
+	}
+	return records, nil
+}
+
+func (imsMeasureSource) UpdateIndex(idx *SourceIndex, records []Record) {
+	idx.LastUpdate = time.Now().In(timezone)
+	idx.extend(records)
+}
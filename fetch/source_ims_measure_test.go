@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envistaResponse = `{
+	"stationId": 16,
+	"data": [
+		{
+			"datetime": "2020-06-20T03:00:00+03:00",
+			"channels": [
+				{"id": 1, "name": "RH", "value": 85},
+				{"id": 2, "name": "TD", "value": 21.5},
+				{"id": 3, "name": "TG", "value": 19.2},
+				{"id": 4, "name": "WD", "value": 290},
+				{"id": 5, "name": "WS", "value": 1.3}
+			]
+		},
+		{
+			"datetime": "2020-06-20T03:10:00+03:00",
+			"channels": [{"id": 2, "name": "TD", "value": 21.6}]
+		}
+	]
+}`
+
+func TestIMSMeasureFetch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "ApiToken test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(envistaResponse))
+	}))
+	defer s.Close()
+	doer := newServerDoer(t, s.URL)
+	setTestGlobals(t, doer, "test-token")
+
+	start := time.Date(2020, time.June, 20, 0, 0, 0, 0, timezone)
+	records, err := imsMeasureSource{}.Fetch(context.Background(), Location{Name: "megido", IMSStation: 16}, start, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/v1/envista/stations/16/data/daily/2020/06/20"}, doer.paths())
+	// Measurements that are not at a round 3 hours are skipped.
+	require.Equal(t, 1, len(records))
+	r := records[0]
+	assert.Equal(t, time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC), r.Time)
+	assert.Equal(t, location("megido"), r.Location)
+	assert.Equal(t, "https://api.ims.gov.il/v1/envista/stations/16/data/daily/2020/06/20", r.Provenance.URL)
+}
+
+func TestIMSMeasureFetchNoStation(t *testing.T) {
+	doer := newServerDoer(t, "http://127.0.0.1:0")
+	setTestGlobals(t, doer, "test-token")
+
+	start := time.Date(2020, time.June, 20, 0, 0, 0, 0, timezone)
+	records, err := imsMeasureSource{}.Fetch(context.Background(), Location{Name: "zefat"}, start, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Empty(t, doer.paths())
+}
+
+func TestIMSMeasureNotConfigured(t *testing.T) {
+	doer := newServerDoer(t, "http://127.0.0.1:0")
+	setTestGlobals(t, doer, "")
+	chdir(t, t.TempDir())
+
+	// The source is skipped, and it does not affect the fail policy.
+	start := time.Date(2020, time.June, 20, 0, 0, 0, 0, timezone)
+	locs := []Location{{Name: "megido", IMSStation: 16}}
+	statuses := run(context.Background(), newStore(), []Source{imsMeasureSource{}}, locs, start, start.AddDate(0, 0, 1), true, nil)
+	assert.Empty(t, statuses)
+	assert.False(t, shouldFail(failAny, statuses))
+	assert.Empty(t, doer.paths())
+
+	idx := index.source("ims-measure")
+	assert.Equal(t, statusSkipped, idx.Status)
+	assert.Contains(t, idx.LastError, "IMS_API_TOKEN is not set")
+}
+
+// serverDoer sends all the requests to a test server, and records their paths.
+type serverDoer struct {
+	server *url.URL
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func newServerDoer(t *testing.T, server string) *serverDoer {
+	u, err := url.Parse(server)
+	require.NoError(t, err)
+	return &serverDoer{server: u}
+}
+
+func (d *serverDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	d.requested = append(d.requested, req.URL.Path)
+	d.mu.Unlock()
+	req.URL.Scheme = d.server.Scheme
+	req.URL.Host = d.server.Host
+	return http.DefaultClient.Do(req)
+}
+
+func (d *serverDoer) paths() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requested...)
+}
+
+// setTestGlobals sets the HTTP client and the IMS token for the rest of the test.
+func setTestGlobals(t *testing.T, doer *serverDoer, token string) {
+	prevClient, prevToken := httpClient, imsToken
+	httpClient, imsToken = doer, token
+	t.Cleanup(func() { httpClient, imsToken = prevClient, prevToken })
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airsounds/data/fetch/ims"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIMSFetch(t *testing.T) {
+	t.Parallel()
+
+	forecastTime := ims.ForecastTime{Time: time.Date(2020, time.June, 20, 12, 0, 0, 0, time.UTC)}
+	s := &imsSource{forecasts: []ims.Forecast{
+		{Name: "AFULA NIR HAEMEQ", Lat: 32.596, Long: 35.2769, Forecast: []ims.HourlyForecast{{Time: forecastTime, Temp: 30}}},
+		{Name: "ZEFAT HAR KENAAN", Lat: 32.98, Long: 35.51, Forecast: []ims.HourlyForecast{{Time: forecastTime, Temp: 20}}},
+	}}
+	s.once.Do(func() {})
+
+	// The IMS name of the location.
+	records, err := s.Fetch(context.Background(), Location{Name: "test-ims-name", IMSName: "ZEFAT HAR KENAAN"}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(records))
+	assert.Equal(t, location("test-ims-name"), records[0].Location)
+
+	// The nearest IMS location.
+	records, err = s.Fetch(context.Background(), Location{Name: "test-ims-nearest", Lat: 32.6, Long: 35.23}, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(records))
+	assert.Equal(t, location("test-ims-nearest"), records[0].Location)
+	assert.Equal(t, float32(30), records[0].Data.(*ims.HourlyForecast).Temp)
+	mappingsMu.Lock()
+	m := index.Mappings["test-ims-nearest"]["ims"]
+	mappingsMu.Unlock()
+	assert.Equal(t, "AFULA NIR HAEMEQ", m.ID)
+	assert.True(t, m.Auto)
+
+	// An IMS name that is not in the forecast fails the fetch of the location.
+	_, err = s.Fetch(context.Background(), Location{Name: "test-ims-missing", IMSName: "AFULA"}, time.Time{}, time.Time{})
+	assert.Error(t, err)
+}
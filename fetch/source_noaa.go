@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/airsounds/data/fetch/noaa"
+)
+
+func init() {
+	register(noaaSource{})
+}
+
+// noaaSource fetches soundings forecast from NOAA.
+type noaaSource struct{}
+
+func (noaaSource) Name() string { return "noaa" }
+
+func (noaaSource) isForecast() {}
+
+func (noaaSource) canBackfill() {}
+
+func (noaaSource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	ns, err := noaa.Get(ctx, httpClient, *noaaModel, *noaaFcstLen, start, end, loc.Lat, loc.Long)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, n := range ns {
+		records = append(records, Record{
+			Time:     n.Time,
+			Location: location(loc.Name),
+			Data:     n,
+			Provenance: Provenance{
+				URL:       redactURL(n.URL),
+				Model:     n.Model,
+				Run:       n.Run,
+				Synthetic: n.Synthetic,
+			},
+		})
+	}
+	return records, nil
+}
+
+func (noaaSource) UpdateIndex(idx *SourceIndex, records []Record) {
+	idx.LastUpdate = time.Now().In(timezone)
+	idx.extend(records)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/airsounds/data/fetch/uwyo"
+)
+
+func init() {
+	register(&uwyoSource{stations: map[stationWindow]*stationFetch{}})
+}
+
+// uwyoSource fetches radiosonde measurements from the University of Wyoming. Measurements are
+// per station and stored under the station number, so a station that is shared by several
+// locations is fetched only once per time window and its result is returned for all of them.
+type uwyoSource struct {
+	mu       sync.Mutex
+	stations map[stationWindow]*stationFetch
+}
+
+type stationWindow struct {
+	station    int
+	start, end time.Time
+}
+
+// stationFetch is the result of fetching a station.
+type stationFetch struct {
+	once    sync.Once
+	records []Record
+	err     error
+}
+
+func (*uwyoSource) Name() string { return "uwyo" }
+
+func (*uwyoSource) canBackfill() {}
+
+func (s *uwyoSource) Fetch(ctx context.Context, loc Location, start, end time.Time) ([]Record, error) {
+	station, m := uwyoStation(loc)
+	index.setMapping(loc, s.Name(), m)
+	key := stationWindow{station: station, start: start, end: end}
+	s.mu.Lock()
+	f := s.stations[key]
+	if f == nil {
+		f = &stationFetch{}
+		s.stations[key] = f
+	}
+	s.mu.Unlock()
+
+	f.once.Do(func() { f.records, f.err = fetchStation(ctx, station, start, end) })
+	return f.records, f.err
+}
+
+func fetchStation(ctx context.Context, station int, start, end time.Time) ([]Record, error) {
+	// Measurements are available only for the past.
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	tables, err := uwyo.FetchRange(ctx, httpClient, station, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, table := range tables {
+		records = append(records, Record{
+			Time:       table.Time,
+			Location:   location(strconv.Itoa(station)),
+			Data:       table,
+			Provenance: Provenance{URL: redactURL(table.URL)},
+		})
+	}
+	return records, nil
+}
+
+func (*uwyoSource) Key(loc Location) location {
+	station, _ := uwyoStation(loc)
+	return location(strconv.Itoa(station))
+}
+
+func (*uwyoSource) UpdateIndex(idx *SourceIndex, records []Record) {
+	idx.extend(records)
+}
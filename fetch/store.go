@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/airsounds/data/fetch/history"
+)
+
+// store holds day files in memory. Day files are loaded on first access, and the modified ones
+// are written once by flush.
+type store struct {
+	days     map[string]dayData
+	modified map[string]bool
+	// migrated holds the day files from which legacy IMS forecasts were removed.
+	migrated map[string]bool
+}
+
+func newStore() *store {
+	return &store{
+		days:     map[string]dayData{},
+		modified: map[string]bool{},
+		migrated: map[string]bool{},
+	}
+}
+
+// day returns the content of a day file. A corrupt day file is recovered according to the
+// recovery mode.
+func (s *store) day(path string) dayData {
+	content, ok := s.days[path]
+	if ok {
+		return content
+	}
+	content = dayData{}
+	err := decodeJson(path, &content)
+	if err != nil {
+		log.Printf("Loading day file: %s", err)
+		err = recoverFile(path, *recoverMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		content = dayData{}
+		mustDecodeJson(path, &content)
+	}
+	s.days[path] = content
+	return content
+}
+
+// add a record of a source to the day file of the record time, with its provenance, and returns
+// the path of the day file. In forecast history mode, the record is also added to the history of
+// forecast sources.
+func (s *store) add(rec Record, src Source, fetched time.Time) (path string) {
+	name := src.Name()
+	h := hour(rec.Time.Hour())
+	l := rec.Location
+	path = outputPath(rec.Time)
+
+	content := s.day(path)
+	// Legacy IMS forecasts are removed from every modified day file, and not only when IMS
+	// forecasts are added, so that no data is derived from them.
+	if !s.migrated[path] {
+		dropLegacyIMS(path, content)
+		s.migrated[path] = true
+	}
+	if content[h] == nil {
+		content[h] = map[location]sources{}
+	}
+	if content[h][l] == nil {
+		content[h][l] = sources{}
+	}
+	content[h][l].set(name, rec.Data)
+	prov := rec.Provenance
+	prov.FetchTime = fetched.In(timezone)
+	prov.Version = version
+	prov.Hash = contentHash(content[h][l][name])
+	content[h][l].setProvenance(name, prov)
+	if _, ok := src.(forecaster); ok && historyEnabled() {
+		issued := prov.FetchTime
+		if prov.Run != nil {
+			issued = prov.Run.In(timezone)
+		}
+		content[h][l].addHistory(name, history.Issuance{
+			Issued: issued,
+			Hash:   prov.Hash,
+			Data:   content[h][l][name],
+		})
+	}
+	s.modified[path] = true
+	return path
+}
+
+// dropLegacyIMS removes from a day file the IMS forecasts that were stored before the IMS
+// forecast times were parsed in the Israel timezone. They are stored under the local hour instead
+// of the UTC hour, so newer forecasts do not overwrite them. They are recognized by not having
+// provenance, which is stored with every record since. The products that were derived from them
+// are removed as well, and are derived again from the remaining data.
+func dropLegacyIMS(path string, content dayData) {
+	dropped := 0
+	for _, locs := range content {
+		for _, srcs := range locs {
+			if _, ok := srcs["ims"]; !ok {
+				continue
+			}
+			var provs map[string]Provenance
+			if srcs.decode(provenanceKey, &provs) {
+				if _, ok := provs["ims"]; ok {
+					continue
+				}
+			}
+			delete(srcs, "ims")
+			delete(srcs, derivedKey)
+			delete(srcs, runwayKey)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.Printf("Removed %d legacy IMS forecasts from %s", dropped, path)
+	}
+}
+
+// paths returns the paths of the modified day files, sorted.
+func (s *store) paths() []string {
+	var paths []string
+	for path := range s.modified {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// flush writes the modified day files and returns their paths.
+func (s *store) flush() []string {
+	paths := s.paths()
+	for _, path := range paths {
+		mustEncodeJson(path, s.days[path])
+	}
+	return paths
+}
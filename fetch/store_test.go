@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/airsounds/data/fetch/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropLegacyIMS(t *testing.T) {
+	t.Parallel()
+
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+	content := dayData{
+		// Legacy forecast, stored under the local hour.
+		18: {
+			"megido": {"ims": raw(`{"Temp": 20}`), "noaa": raw(`{}`), derivedKey: raw(`{}`), runwayKey: raw(`{}`)},
+		},
+		16: {
+			"megido": {"ims": raw(`{"Temp": 20}`), provenanceKey: raw(`{"ims": {"Hash": "a"}}`), derivedKey: raw(`{}`)},
+			"zefat":  {"noaa": raw(`{}`), derivedKey: raw(`{}`)},
+		},
+	}
+
+	dropLegacyIMS("2020/06/20.json", content)
+
+	assert.Equal(t, sources{"noaa": raw(`{}`)}, content[18]["megido"])
+	assert.Contains(t, content[16]["megido"], "ims")
+	assert.Contains(t, content[16]["megido"], derivedKey)
+	assert.Contains(t, content[16]["zefat"], derivedKey)
+}
+
+func TestStoreAddDropsLegacyIMS(t *testing.T) {
+	chdir(t, t.TempDir())
+	defer func(l []Location) { locations = l }(locations)
+	locations = []Location{{Name: "megido"}}
+
+	// A day file with a legacy IMS forecast under the local hour, and the products that were
+	// derived from it.
+	day := time.Date(2024, time.September, 30, 12, 0, 0, 0, time.UTC)
+	path := outputPath(day)
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+	mustEncodeJson(path, dayData{
+		15: {"megido": {"ims": raw(`{"Temp": 20}`), derivedKey: raw(`{}`), runwayKey: raw(`{}`)}},
+	})
+
+	// A NOAA only run over the day.
+	st := newStore()
+	st.add(Record{Time: day, Location: "megido", Data: map[string]int{"run": 0}}, noaaSource{}, day)
+	derive(st)
+	st.flush()
+
+	var content dayData
+	mustDecodeJson(path, &content)
+	assert.Empty(t, content[15]["megido"])
+	assert.Contains(t, content[12]["megido"], "noaa")
+	assert.NotContains(t, content[12]["megido"], "ims")
+	assert.NotContains(t, content[12]["megido"], derivedKey)
+}
+
+func TestStoreAddHistory(t *testing.T) {
+	chdir(t, t.TempDir())
+	defer func(d time.Duration) { historyInterval = d }(historyInterval)
+	historyInterval = 6 * time.Hour
+
+	target := time.Date(2020, time.June, 20, 12, 0, 0, 0, time.UTC)
+	fetched := time.Date(2020, time.June, 19, 0, 0, 0, 0, time.UTC)
+	st := newStore()
+	for i, run := range []int{0, 6, 9} {
+		r := fetched.Add(time.Duration(run) * time.Hour)
+		st.add(Record{
+			Time:       target,
+			Location:   "megido",
+			Data:       map[string]int{"run": run},
+			Provenance: Provenance{Run: &r},
+		}, noaaSource{}, fetched.Add(time.Duration(i)*time.Minute))
+	}
+
+	// Only -history-interval is set, the latest issuance of each 6 hours is kept.
+	srcs := st.day(outputPath(target))[12]["megido"]
+	var hists map[string][]history.Issuance
+	require.True(t, srcs.decode(historyKey, &hists))
+	var runs []string
+	for _, iss := range hists["noaa"] {
+		runs = append(runs, string(iss.Data))
+	}
+	assert.Equal(t, []string{`{"run":0}`, `{"run":9}`}, runs)
+}
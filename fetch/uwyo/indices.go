@@ -0,0 +1,117 @@
+package uwyo
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Indices are the station information and sounding indices that UWYO computes for a sounding.
+// Indices that are missing from the sounding are nil.
+type Indices struct {
+	// Station latitude and longitude in degrees.
+	Lat  *float32 `json:",omitempty"`
+	Long *float32 `json:",omitempty"`
+	// Station elevation in meters.
+	Elevation *float32 `json:",omitempty"`
+
+	Showalter *float32 `json:",omitempty"`
+	// LI is the lifted index.
+	LI *float32 `json:",omitempty"`
+	// LIV is the lifted index computed using virtual temperature.
+	LIV           *float32 `json:",omitempty"`
+	KIndex        *float32 `json:",omitempty"`
+	CrossTotals   *float32 `json:",omitempty"`
+	VerticalTotal *float32 `json:",omitempty"`
+	TotalTotals   *float32 `json:",omitempty"`
+	// CAPE in J/kg, and CAPEV computed using virtual temperature.
+	CAPE  *float32 `json:",omitempty"`
+	CAPEV *float32 `json:",omitempty"`
+	// CIN in J/kg, and CINV computed using virtual temperature.
+	CIN  *float32 `json:",omitempty"`
+	CINV *float32 `json:",omitempty"`
+	// BRN is the bulk Richardson number, and BRNV is computed using CAPEV.
+	BRN  *float32 `json:",omitempty"`
+	BRNV *float32 `json:",omitempty"`
+	// LCLTemp is the temperature in K of the lifted condensation level.
+	LCLTemp *float32 `json:",omitempty"`
+	// LCLPres is the pressure in hPa of the lifted condensation level.
+	LCLPres *float32 `json:",omitempty"`
+	// LCLThetaE is the equivalent potential temperature in K of the lifted condensation level.
+	LCLThetaE *float32 `json:",omitempty"`
+	// MixedLayerTheta is the mean mixed layer potential temperature in K.
+	MixedLayerTheta *float32 `json:",omitempty"`
+	// MixedLayerMixR is the mean mixed layer mixing ratio in g/kg.
+	MixedLayerMixR *float32 `json:",omitempty"`
+	// Thickness is the 1000 hPa to 500 hPa thickness in meters.
+	Thickness *float32 `json:",omitempty"`
+	// PW is the precipitable water in mm for the entire sounding.
+	PW *float32 `json:",omitempty"`
+}
+
+// fields maps the labels of the indices block to the indices fields.
+func (i *Indices) fields() map[string]**float32 {
+	return map[string]**float32{
+		"Station latitude":                            &i.Lat,
+		"Station longitude":                           &i.Long,
+		"Station elevation":                           &i.Elevation,
+		"Showalter index":                             &i.Showalter,
+		"Lifted index":                                &i.LI,
+		"LIFT computed using virtual temperature":     &i.LIV,
+		"K index":                                     &i.KIndex,
+		"Cross totals index":                          &i.CrossTotals,
+		"Vertical totals index":                       &i.VerticalTotal,
+		"Totals totals index":                         &i.TotalTotals,
+		"Convective Available Potential Energy":       &i.CAPE,
+		"CAPE using virtual temperature":              &i.CAPEV,
+		"Convective Inhibition":                       &i.CIN,
+		"CINS using virtual temperature":              &i.CINV,
+		"Bulk Richardson Number":                      &i.BRN,
+		"Bulk Richardson Number using CAPV":           &i.BRNV,
+		"Temp [K] of the Lifted Condensation Level":   &i.LCLTemp,
+		"Pres [hPa] of the Lifted Condensation Level": &i.LCLPres,
+		"Equivalent potential temp [K] of the LCL":    &i.LCLThetaE,
+		"Mean mixed layer potential temperature":      &i.MixedLayerTheta,
+		"Mean mixed layer mixing ratio":               &i.MixedLayerMixR,
+		"1000 hPa to 500 hPa thickness":               &i.Thickness,
+		"Precipitable water [mm] for entire sounding": &i.PW,
+	}
+}
+
+// parseIndices parses the "Station information and sounding indices" block. It returns the
+// indices and the station number.
+func parseIndices(text string) (*Indices, int, error) {
+	var (
+		indices Indices
+		station int
+		fields  = indices.fields()
+	)
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		label, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if label == "Station number" {
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("station number %q: %s", value, err)
+			}
+			station = v
+			continue
+		}
+		field, ok := fields[label]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s %q: %s", label, value, err)
+		}
+		f := float32(v)
+		*field = &f
+	}
+	return &indices, station, s.Err()
+}
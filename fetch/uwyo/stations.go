@@ -0,0 +1,40 @@
+package uwyo
+
+// StationInfo describes a radiosonde station.
+type StationInfo struct {
+	// Number is the WMO station number.
+	Number int
+	Name   string
+	Lat    float32
+	Long   float32
+	// Alt is the station elevation in meters.
+	Alt int
+}
+
+// Stations is the list of known radiosonde stations in the UWYO "mideast" region.
+var Stations = []StationInfo{
+	{Number: 17064, Name: "Istanbul Kartal", Lat: 40.90, Long: 29.15, Alt: 18},
+	{Number: 17130, Name: "Ankara Central", Lat: 39.95, Long: 32.88, Alt: 891},
+	{Number: 17220, Name: "Izmir", Lat: 38.43, Long: 27.17, Alt: 29},
+	{Number: 17240, Name: "Isparta", Lat: 37.78, Long: 30.55, Alt: 997},
+	{Number: 17351, Name: "Adana", Lat: 37.05, Long: 35.35, Alt: 27},
+	{Number: 40179, Name: "Bet Dagan", Lat: 32.00, Long: 34.81, Alt: 35},
+	{Number: 40265, Name: "Mafraq", Lat: 32.37, Long: 36.25, Alt: 687},
+	{Number: 40375, Name: "Tabuk", Lat: 28.38, Long: 36.60, Alt: 768},
+	{Number: 40437, Name: "Riyadh", Lat: 24.93, Long: 46.72, Alt: 612},
+	{Number: 40582, Name: "Kuwait", Lat: 29.22, Long: 47.98, Alt: 55},
+	{Number: 40754, Name: "Tehran Mehrabad", Lat: 35.68, Long: 51.32, Alt: 1191},
+	{Number: 41024, Name: "Jeddah", Lat: 21.70, Long: 39.18, Alt: 12},
+	{Number: 62337, Name: "El Arish", Lat: 31.08, Long: 33.82, Alt: 32},
+	{Number: 62378, Name: "Helwan", Lat: 29.86, Long: 31.33, Alt: 141},
+}
+
+// LookupStation returns the information of a station by its number.
+func LookupStation(number int) (StationInfo, bool) {
+	for _, s := range Stations {
+		if s.Number == number {
+			return s, true
+		}
+	}
+	return StationInfo{}, false
+}
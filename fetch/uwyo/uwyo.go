@@ -2,7 +2,7 @@ package uwyo
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -12,61 +12,114 @@ import (
 	"strings"
 	"time"
 
+	"github.com/airsounds/data/fetch/retry"
 	"golang.org/x/net/html"
 )
 
 const soundingURL = "http://weather.uwyo.edu/cgi-bin/sounding"
 
-var timezone, _ = time.LoadLocation("Asia/Jerusalem")
-
 // UWYO forcast information.
+//
+// All the level columns are aligned, the i'th value of each column belongs to the i'th level of
+// the sounding. Values that are missing from the sounding are nil.
 type UWYO struct {
 	// Time of Forecast
 	Time    time.Time
 	Station int
 	// Pressure in hPa
-	Pressure []int
+	Pressure []*float32
 	// Height in feet
-	Height []int
+	Height []*int
 	// Temp in Deg C
-	Temp []float32
+	Temp []*float32
 	// Dew point in deg C
-	Dew []float32
+	Dew []*float32
+	// RelHum is the relative humidity in percent.
+	RelHum []*int
+	// MixRatio is the mixing ratio in g/kg.
+	MixRatio []*float32
 	// WindDir in degrees
-	WindDir []int
+	WindDir []*int
 	// WindSpeed in knots
-	WindSpeed []int
+	WindSpeed []*int
+	// Theta is the potential temperature in K.
+	Theta []*float32
+	// ThetaE is the equivalent potential temperature in K.
+	ThetaE []*float32
+	// ThetaV is the virtual potential temperature in K.
+	ThetaV []*float32
+	// Indices are the station information and sounding indices.
+	Indices *Indices `json:",omitempty"`
+	// URL from which the sounding was fetched.
+	URL string `json:"-"`
 }
 
-func Fetch(station int, t time.Time) ([]*UWYO, error) {
-	// Measurement are only available in 12 hours periods, at 00 and 12.
-	hour := "00"
-	if t.Hour() > 12 {
-		hour = "12"
+// FetchRange returns all the soundings of a station between from and to (inclusive), including
+// the 06Z and 18Z soundings for stations that publish them. Each sounding is returned once, even
+// if it is returned by the requests of two months.
+func FetchRange(ctx context.Context, client retry.Doer, station int, from, to time.Time) ([]*UWYO, error) {
+	from, to = from.UTC(), to.UTC()
+
+	// A request is limited to a single month.
+	var tables []*UWYO
+	seen := map[time.Time]bool{}
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+		first, last := month, month.AddDate(0, 1, 0).Add(-time.Hour)
+		if first.Before(from) {
+			first = from
+		}
+		if last.After(to) {
+			last = to
+		}
+		monthTables, err := fetch(ctx, client, station, first, last)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range monthTables {
+			if !t.Time.Before(from) && !t.Time.After(to) && !seen[t.Time] {
+				seen[t.Time] = true
+				tables = append(tables, t)
+			}
+		}
 	}
-	req, err := http.NewRequest(http.MethodGet, soundingURL, nil)
+	return tables, nil
+}
+
+// fetch the soundings between the hours of first and last, which must be in the same month.
+func fetch(ctx context.Context, client retry.Doer, station int, first, last time.Time) ([]*UWYO, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, soundingURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	q := url.Values{}
 	q.Set("region", "mideast")
-	q.Set("STNM", strconv.Itoa(station)) // Bet dagan station.
+	q.Set("STNM", strconv.Itoa(station))
 	q.Set("TYPE", "TEXT:LIST")
-	q.Set("YEAR", fmt.Sprintf("%4d", t.Year()))
-	q.Set("MONTH", fmt.Sprintf("%02d", t.Month()))
-	q.Set("FROM", fmt.Sprintf("%02d%s", t.Day(), hour))
-	q.Set("TO", fmt.Sprintf("%02d%s", t.Day(), hour))
+	q.Set("YEAR", fmt.Sprintf("%4d", first.Year()))
+	q.Set("MONTH", fmt.Sprintf("%02d", first.Month()))
+	q.Set("FROM", fmt.Sprintf("%02d%02d", first.Day(), first.Hour()))
+	q.Set("TO", fmt.Sprintf("%02d%02d", last.Day(), last.Hour()))
 	req.URL.RawQuery = q.Encode()
 
 	log.Printf("Fetching from URL %s", req.URL)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
 
-	return parseBody(resp.Body)
+	tables, err := parseBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		table.URL = req.URL.String()
+	}
+	return tables, nil
 }
 
 func parseBody(r io.Reader) ([]*UWYO, error) {
@@ -102,6 +155,21 @@ func parseBody(r io.Reader) ([]*UWYO, error) {
 		if table == nil {
 			break
 		}
+
+		// The table may be followed by the station information and sounding indices block.
+		if h3 := nextElement(tableNode); h3 != nil && h3.Data == "h3" {
+			if pre := nextElement(h3); pre != nil && pre.Data == "pre" && pre.FirstChild != nil {
+				n = pre.NextSibling
+				var station int
+				table.Indices, station, err = parseIndices(pre.FirstChild.Data)
+				if err != nil {
+					return nil, fmt.Errorf("parsing indices: %s", err)
+				}
+				if station != 0 {
+					table.Station = station
+				}
+			}
+		}
 		tables = append(tables, table)
 	}
 
@@ -118,50 +186,158 @@ func parseTable(headerNode *html.Node, tableNode *html.Node) (*UWYO, error) {
 	if text.Type != html.TextNode {
 		return nil, fmt.Errorf("expected text node within the h2 node")
 	}
-	table.Time, err = parseHeader(text.Data)
+	table.Station, table.Time, err = parseHeader(text.Data)
 	if err != nil {
 		return nil, fmt.Errorf("parsing header: %s", err)
 	}
 
-	tableText := tableNode.FirstChild.Data
-	log.Println("Table:", tableText)
-	s := bufio.NewScanner(bytes.NewBufferString(tableText))
-	// Skip header:
-	for i := 0; i < 4; i++ {
-		s.Scan()
+	if tableNode.FirstChild == nil {
+		return nil, fmt.Errorf("empty table")
+	}
+	err = table.parseLevels(tableNode.FirstChild.Data)
+	if err != nil {
+		return nil, err
 	}
+	return &table, nil
+}
+
+// columns maps the column names of the sounding table to parsers that append a cell to the
+// matching level column. An empty cell is appended as nil.
+func (u *UWYO) columns() map[string]func(string) error {
+	return map[string]func(string) error{
+		"PRES": floatColumn(&u.Pressure),
+		"HGHT": intColumn(&u.Height, 3.28084), // Convert meters to feet.
+		"TEMP": floatColumn(&u.Temp),
+		"DWPT": floatColumn(&u.Dew),
+		"RELH": intColumn(&u.RelHum, 1),
+		"MIXR": floatColumn(&u.MixRatio),
+		"DRCT": intColumn(&u.WindDir, 1),
+		"SKNT": intColumn(&u.WindSpeed, 1),
+		"THTA": floatColumn(&u.Theta),
+		"THTE": floatColumn(&u.ThetaE),
+		"THTV": floatColumn(&u.ThetaV),
+	}
+}
 
-	// Scan table content.
-	fieldLen := 7
+// parseLevels parses the fixed width levels table. The table starts with a line of column names
+// followed by a units line, and the names are right aligned with the values of their column.
+func (u *UWYO) parseLevels(text string) error {
+	var (
+		parsers = u.columns()
+		cols    []column
+		units   bool
+	)
+	s := bufio.NewScanner(strings.NewReader(text))
 	for s.Scan() {
 		line := s.Text()
-		i := 0
-		appendInt(&table.Pressure, line[i:i+fieldLen], 1)
-		i += fieldLen
-		appendInt(&table.Height, line[i:i+fieldLen], 3.28084) // Convert meters to feet.
-		i += fieldLen
-		appendFloat(&table.Temp, line[i:i+fieldLen], 1)
-		i += fieldLen
-		appendFloat(&table.Dew, line[i:i+fieldLen], 1)
-		i += fieldLen * 3
-		appendInt(&table.WindDir, line[i:i+fieldLen], 1)
-		i += fieldLen
-		appendInt(&table.WindSpeed, line[i:i+fieldLen], 1)
-	}
-	return &table, s.Err()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "---") {
+			continue
+		}
+		if cols == nil {
+			var err error
+			cols, err = parseColumns(line)
+			if err != nil {
+				return err
+			}
+			units = true
+			continue
+		}
+		if units {
+			// Skip the units line, that follows the column names.
+			units = false
+			continue
+		}
+		for _, c := range cols {
+			parse, ok := parsers[c.name]
+			if !ok {
+				continue
+			}
+			err := parse(c.cell(line))
+			if err != nil {
+				return fmt.Errorf("column %s in line %q: %s", c.name, line, err)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if cols == nil {
+		return fmt.Errorf("table header not found")
+	}
+	return nil
+}
+
+// column is a column of the fixed width levels table, spanning [start, end) in each line.
+type column struct {
+	name       string
+	start, end int
+}
+
+// parseColumns parses the column names line. Each column spans from the end of the previous
+// column name to the end of its own name.
+func parseColumns(line string) ([]column, error) {
+	var (
+		cols []column
+		end  int
+	)
+	for _, name := range strings.Fields(line) {
+		i := strings.Index(line[end:], name)
+		c := column{name: name, start: end, end: end + i + len(name)}
+		cols = append(cols, c)
+		end = c.end
+	}
+	var pres, hght bool
+	for _, c := range cols {
+		pres = pres || c.name == "PRES"
+		hght = hght || c.name == "HGHT"
+	}
+	if !pres || !hght {
+		return nil, fmt.Errorf("invalid table header: %q", line)
+	}
+	return cols, nil
 }
 
-func parseHeader(s string) (time.Time, error) {
+// cell returns the trimmed content of the column in the given line.
+func (c column) cell(line string) string {
+	start, end := c.start, c.end
+	if end > len(line) {
+		end = len(line)
+	}
+	if start >= end {
+		return ""
+	}
+	return strings.TrimSpace(line[start:end])
+}
+
+// parseHeader parses the station number and time of a header in the format
+// "40179  Bet Dagan Observations at 00Z 17 Feb 2022".
+func parseHeader(s string) (int, time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, time.Time{}, fmt.Errorf("empty header")
+	}
+	station, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("station number in: %s", s)
+	}
 	i := strings.Index(s, " at ")
 	if i == -1 {
-		return time.Time{}, fmt.Errorf("didn't find 'at' in: %s", s)
+		return 0, time.Time{}, fmt.Errorf("didn't find 'at' in: %s", s)
 	}
 	s = s[i+4:]
-	t, err := time.ParseInLocation("15Z 02 Jan 2006", s, timezone)
-	if err != nil {
-		return t, err
+	// The sounding time is in UTC.
+	t, err := time.Parse("15Z 02 Jan 2006", s)
+	return station, t, err
+}
+
+// nextElement returns the next sibling element node.
+func nextElement(n *html.Node) *html.Node {
+	for n = n.NextSibling; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode {
+			return n
+		}
 	}
-	return t, nil
+	return nil
 }
 
 func findElement(n *html.Node, tag string) (*html.Node, error) {
@@ -176,20 +352,34 @@ func findElement(n *html.Node, tag string) (*html.Node, error) {
 	return nil, fmt.Errorf("didn't find '%s' node", tag)
 }
 
-func appendInt(a *[]int, s string, scale float32) error {
-	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
-	if err != nil {
-		return err
+func intColumn(a *[]*int, scale float32) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			*a = append(*a, nil)
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		i := int(float32(v) * scale)
+		*a = append(*a, &i)
+		return nil
 	}
-	*a = append(*a, int(float32(v)*scale))
-	return nil
 }
 
-func appendFloat(a *[]float32, s string, scale float32) error {
-	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
-	if err != nil {
-		return err
+func floatColumn(a *[]*float32) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			*a = append(*a, nil)
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f := float32(v)
+		*a = append(*a, &f)
+		return nil
 	}
-	*a = append(*a, float32(v)*scale)
-	return nil
 }
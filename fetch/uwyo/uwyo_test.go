@@ -2,7 +2,13 @@ package uwyo
 
 import (
 	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,23 +25,202 @@ func TestParseBody(t *testing.T) {
 	require.Equal(t, 1, len(tables))
 
 	table := tables[0]
+	assert.Equal(t, time.Date(2022, time.February, 17, 0, 0, 0, 0, time.UTC), table.Time)
+	assert.Equal(t, 40179, table.Station)
+
+	require.NotNil(t, table.Indices)
+	indices := table.Indices
+	assertIndex(t, 32.00, indices.Lat)
+	assertIndex(t, 34.81, indices.Long)
+	assertIndex(t, 35.0, indices.Elevation)
+	assertIndex(t, 15.55, indices.Showalter)
+	assertIndex(t, 10.55, indices.LI)
+	assertIndex(t, 10.50, indices.LIV)
+	assertIndex(t, 0, indices.CAPE)
+	assertIndex(t, 0, indices.CIN)
+	assertIndex(t, 278.97, indices.LCLTemp)
+	assertIndex(t, 916.89, indices.LCLPres)
+	assertIndex(t, 5529, indices.Thickness)
+	assertIndex(t, 11.86, indices.PW)
+
 	want := 59
 	wantWind := 8
-	assert.Equal(t, want, len(table.Height))
-	assert.Equal(t, want, len(table.Pressure))
-	assert.Equal(t, want, len(table.Temp))
-	assert.Equal(t, want, len(table.Dew))
-	assert.Equal(t, wantWind, len(table.WindDir))
-	assert.Equal(t, wantWind, len(table.WindSpeed))
+	for name, n := range map[string]int{
+		"Pressure":  len(table.Pressure),
+		"Height":    len(table.Height),
+		"Temp":      len(table.Temp),
+		"Dew":       len(table.Dew),
+		"RelHum":    len(table.RelHum),
+		"MixRatio":  len(table.MixRatio),
+		"WindDir":   len(table.WindDir),
+		"WindSpeed": len(table.WindSpeed),
+		"Theta":     len(table.Theta),
+		"ThetaE":    len(table.ThetaE),
+		"ThetaV":    len(table.ThetaV),
+	} {
+		assert.Equal(t, want, n, "len(table.%s)", name)
+	}
 
 	for i := 0; i < want; i++ {
-		assert.NotEqual(t, 0, table.Height[i], "table.Height[%d]", i)
-		assert.NotEqual(t, 0, table.Pressure[i], "table.Pressure[%d]", i)
-		assert.NotEqual(t, 0, table.Temp[i], "table.Temp[%d]", i)
-		assert.NotEqual(t, 0, table.Dew[i], "table.Dew[%d]", i)
+		assert.NotNil(t, table.Height[i], "table.Height[%d]", i)
+		assert.NotNil(t, table.Pressure[i], "table.Pressure[%d]", i)
+		assert.NotNil(t, table.Temp[i], "table.Temp[%d]", i)
+		assert.NotNil(t, table.Dew[i], "table.Dew[%d]", i)
+		assert.NotNil(t, table.RelHum[i], "table.RelHum[%d]", i)
+		assert.NotNil(t, table.Theta[i], "table.Theta[%d]", i)
 		if i < wantWind {
-			assert.NotEqual(t, 0, table.WindSpeed[i], "table.WindSpeed[%d]", i)
-			assert.NotEqual(t, 0, table.WindDir[i], "table.WindDir[%d]", i)
+			assert.NotNil(t, table.WindSpeed[i], "table.WindSpeed[%d]", i)
+			assert.NotNil(t, table.WindDir[i], "table.WindDir[%d]", i)
+		} else {
+			assert.Nil(t, table.WindSpeed[i], "table.WindSpeed[%d]", i)
+			assert.Nil(t, table.WindDir[i], "table.WindDir[%d]", i)
 		}
 	}
+
+	// First level: 1013.0 35 8.0 6.9 93 6.19 150 2 280.1 297.2 281.2
+	assert.Equal(t, float32(1013), *table.Pressure[0])
+	assert.Equal(t, 114, *table.Height[0])
+	assert.Equal(t, float32(8), *table.Temp[0])
+	assert.Equal(t, float32(6.9), *table.Dew[0])
+	assert.Equal(t, 93, *table.RelHum[0])
+	assert.Equal(t, float32(6.19), *table.MixRatio[0])
+	assert.Equal(t, 150, *table.WindDir[0])
+	assert.Equal(t, 2, *table.WindSpeed[0])
+	assert.Equal(t, float32(280.1), *table.Theta[0])
+	assert.Equal(t, float32(297.2), *table.ThetaE[0])
+	assert.Equal(t, float32(281.2), *table.ThetaV[0])
+
+	// Last level, that has no wind.
+	assert.Equal(t, float32(17.7), *table.Pressure[want-1])
+	assert.Equal(t, float32(-87.1), *table.Dew[want-1])
+}
+
+func TestFetchRange(t *testing.T) {
+	t.Parallel()
+
+	// The server returns the soundings of the requested month, and the January response also
+	// includes the first sounding of February.
+	soundings := map[string][]time.Time{
+		"01": {
+			time.Date(2022, time.January, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.January, 31, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"02": {
+			time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.February, 1, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, time.February, 1, 18, 0, 0, 0, time.UTC),
+		},
+	}
+	var (
+		mu      sync.Mutex
+		queries []url.Values
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		w.Write(soundingsPage(t, soundings[r.URL.Query().Get("MONTH")]))
+	}))
+	defer s.Close()
+
+	from := time.Date(2022, time.January, 31, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, time.February, 1, 12, 0, 0, 0, time.UTC)
+	tables, err := FetchRange(context.Background(), serverDoer(s.URL), 40179, from, to)
+	require.NoError(t, err)
+
+	var queried []string
+	for _, q := range queries {
+		assert.Equal(t, "40179", q.Get("STNM"))
+		queried = append(queried, q.Get("YEAR")+"/"+q.Get("MONTH")+" "+q.Get("FROM")+"-"+q.Get("TO"))
+	}
+	assert.Equal(t, []string{"2022/01 3100-3123", "2022/02 0100-0112"}, queried)
+
+	var times []time.Time
+	for _, table := range tables {
+		times = append(times, table.Time)
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2022, time.January, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, time.January, 31, 12, 0, 0, 0, time.UTC),
+		time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, time.February, 1, 12, 0, 0, 0, time.UTC),
+	}, times)
+	assert.Contains(t, tables[0].URL, "MONTH=01")
+	assert.Contains(t, tables[3].URL, "MONTH=02")
+}
+
+// soundingsPage returns the test web page with its sounding repeated for each of the given
+// times.
+func soundingsPage(t *testing.T, times []time.Time) []byte {
+	const header = "00Z 17 Feb 2022"
+	start := bytes.Index(webpage, []byte("<h2>"))
+	end := bytes.LastIndex(webpage, []byte("</pre>")) + len("</pre>")
+	require.True(t, start >= 0 && end > start)
+	sounding := webpage[start:end]
+	require.True(t, bytes.Contains(sounding, []byte(header)))
+
+	var page []byte
+	page = append(page, webpage[:start]...)
+	for _, tm := range times {
+		page = append(page, bytes.Replace(sounding, []byte(header), []byte(tm.Format("15Z 02 Jan 2006")), 1)...)
+	}
+	return append(page, webpage[end:]...)
+}
+
+// serverDoer sends all the requests to the given server.
+type serverDoer string
+
+func (d serverDoer) Do(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(string(d))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	return http.DefaultClient.Do(req)
+}
+
+func TestParseLevelsMissingCells(t *testing.T) {
+	t.Parallel()
+
+	text := `-----------------------------------------------------------------------------
+   PRES   HGHT   TEMP   DWPT   RELH   MIXR   DRCT   SKNT   THTA   THTE   THTV
+    hPa     m      C      C      %    g/kg    deg   knot     K      K      K 
+-----------------------------------------------------------------------------
+ 1000.0    100   20.0                         270     10  293.0
+  900.0   1000   12.0    2.0     50   4.00
+`
+	var u UWYO
+	require.NoError(t, u.parseLevels(text))
+	require.Equal(t, 2, len(u.Pressure))
+	require.Equal(t, 2, len(u.ThetaV))
+
+	assert.Nil(t, u.Dew[0])
+	assert.Nil(t, u.RelHum[0])
+	assert.Equal(t, 270, *u.WindDir[0])
+	assert.Equal(t, float32(293), *u.Theta[0])
+	assert.Nil(t, u.ThetaE[0])
+
+	assert.Equal(t, float32(2), *u.Dew[1])
+	assert.Nil(t, u.WindDir[1])
+	assert.Nil(t, u.WindSpeed[1])
+	assert.Nil(t, u.Theta[1])
+}
+
+func TestParseLevelsInvalidCell(t *testing.T) {
+	t.Parallel()
+
+	text := `   PRES   HGHT   TEMP
+    hPa     m      C
+ 1000.0    100    abc
+`
+	var u UWYO
+	assert.Error(t, u.parseLevels(text))
+}
+
+func assertIndex(t *testing.T, want float32, got *float32) {
+	t.Helper()
+	if assert.NotNil(t, got) {
+		assert.Equal(t, want, *got)
+	}
 }